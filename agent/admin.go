@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AdminTokenEnv names the environment variable holding the bearer token that
+// admin requests must present. When unset the admin API is unauthenticated.
+const AdminTokenEnv = "SWEEPER_ADMIN_TOKEN"
+
+type ruleStatus struct {
+	Name      string     `json:"name"`
+	Direction string     `json:"direction"`
+	Schedule  string     `json:"schedule"`
+	Paused    bool       `json:"paused"`
+	Running   bool       `json:"running"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	PrevRun   *time.Time `json:"prev_run,omitempty"`
+	// SkippedRuns counts runs skipped because the previous one was still in
+	// progress; LastSkipped is when that last happened.
+	SkippedRuns int        `json:"skipped_runs"`
+	LastSkipped *time.Time `json:"last_skipped,omitempty"`
+}
+
+// AdminHandler returns the handler serving the admin API:
+//
+//	GET  /rules                 rules with their next and previous fire times
+//	                            and skipped runs
+//	POST /rules/{name}/trigger  run a rule immediately
+//	POST /rules/{name}/pause    skip scheduled and threshold runs of a rule
+//	POST /rules/{name}/resume   undo a pause
+//	GET  /transfers             in-flight transfers and their approval URLs
+//	GET  /config                the effective configuration, without sink URLs
+//	                            and credential locations
+func (a *SweeperAgent) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rules", a.handleRules)
+	mux.HandleFunc("/rules/", a.handleRuleAction)
+	mux.HandleFunc("/transfers", a.handleTransfers)
+	mux.HandleFunc("/config", a.handleConfig)
+
+	token := os.Getenv(AdminTokenEnv)
+	if token == "" {
+		return mux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (a *SweeperAgent) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	statuses := make([]ruleStatus, 0, len(a.config.Rules))
+	for _, rule := range a.config.Rules {
+		status := ruleStatus{
+			Name:      rule.Name,
+			Direction: rule.Direction,
+			Schedule:  rule.Schedule,
+			Paused:    a.paused[rule.Name],
+			Running:   a.runs.IsRunning(rule.Name),
+		}
+		if skipped := a.runs.skippedRuns(rule.Name); skipped.Count > 0 {
+			status.SkippedRuns = skipped.Count
+			status.LastSkipped = &skipped.Last
+		}
+		if entryId, exists := a.entries[rule.Name]; exists {
+			entry := a.cron.Entry(entryId)
+			if !entry.Next.IsZero() {
+				status.NextRun = &entry.Next
+			}
+			if !entry.Prev.IsZero() {
+				status.PrevRun = &entry.Prev
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (a *SweeperAgent) handleRuleAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/rules/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	ruleName, action := parts[0], parts[1]
+
+	rule, exists := a.findRule(ruleName)
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("rule '%s' not found", ruleName))
+		return
+	}
+
+	switch action {
+	case "trigger":
+		if a.runs.IsRunning(rule.Name) {
+			writeError(w, http.StatusConflict, fmt.Sprintf("rule '%s' is already running", rule.Name))
+			return
+		}
+		zap.L().Info("rule triggered through admin API", zap.String("rule", rule.Name))
+		a.jobs.Add(1)
+		go func() {
+			defer a.jobs.Done()
+			a.executeRule(rule, time.Now().Truncate(time.Second), nil)
+		}()
+		writeJSON(w, http.StatusAccepted, map[string]string{"rule": rule.Name, "status": "triggered"})
+	case "pause":
+		a.setPaused(rule.Name, true)
+		zap.L().Info("rule paused through admin API", zap.String("rule", rule.Name))
+		writeJSON(w, http.StatusOK, map[string]string{"rule": rule.Name, "status": "paused"})
+	case "resume":
+		a.setPaused(rule.Name, false)
+		zap.L().Info("rule resumed through admin API", zap.String("rule", rule.Name))
+		writeJSON(w, http.StatusOK, map[string]string{"rule": rule.Name, "status": "resumed"})
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown action '%s'", action))
+	}
+}
+
+func (a *SweeperAgent) handleTransfers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	records, err := a.ledger.NonTerminal()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (a *SweeperAgent) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redactConfig(a.getConfig()))
+}
+
+// redacted replaces the config values /config does not serve.
+const redacted = "[redacted]"
+
+// redactConfig returns a copy of config without sink URLs and credential
+// locations, which would let an unauthenticated reader post to the sinks or
+// find the Prime credentials.
+func redactConfig(config *model.Config) *model.Config {
+	redactedConfig := *config
+	redactedConfig.Notifications.WebhookUrl = redact(config.Notifications.WebhookUrl)
+
+	redactedConfig.Notifications.Sinks = make([]model.NotificationSink, len(config.Notifications.Sinks))
+	for i, sink := range config.Notifications.Sinks {
+		sink.Url = redact(sink.Url)
+		sink.Username = redact(sink.Username)
+		redactedConfig.Notifications.Sinks[i] = sink
+	}
+
+	redactedConfig.Portfolios = make([]model.Portfolio, len(config.Portfolios))
+	for i, portfolio := range config.Portfolios {
+		if portfolio.Credentials != nil {
+			source := *portfolio.Credentials
+			source.Env = redact(source.Env)
+			source.Path = redact(source.Path)
+			source.Address = redact(source.Address)
+			source.TokenEnv = redact(source.TokenEnv)
+			if len(source.Command) > 0 {
+				source.Command = []string{redacted}
+			}
+			portfolio.Credentials = &source
+		}
+		portfolio.CredentialsEnv = redact(portfolio.CredentialsEnv)
+		redactedConfig.Portfolios[i] = portfolio
+	}
+	return &redactedConfig
+}
+
+// redact hides value, keeping empty values empty so that unset fields still
+// read as unset.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		zap.L().Error("failed to write admin response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
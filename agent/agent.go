@@ -1,81 +1,511 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/metrics"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
 	"github.com/coinbase-samples/prime-sweeper-go/utils"
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
+	"net/http"
 	"os"
 	"sync"
+	"syscall"
+	"time"
 )
 
+const defaultShutdownTimeout model.Seconds = 30
+
 type SweeperAgent struct {
-	config *model.Config
-	cron   *cron.Cron
+	clients    utils.ClientResolver
+	limiter    *utils.RateLimiter
+	configPath string
+	cron       *cron.Cron
+	ledger     *store.Ledger
+	jobs       sync.WaitGroup
+	runs       *RunGuard
+	tracker    *core.Tracker
+	// oneShot agents run single commands: they neither resume transfers
+	// nor track the ones they submit, leaving both to the daemon.
+	oneShot bool
+
+	// mu guards config, portfolios, entries, paused and reloaded, which
+	// change on reload and through the admin API.
+	mu         sync.Mutex
+	config     *model.Config
+	reloaded   chan struct{}
+	portfolios map[string]*core.Portfolio
+	entries    map[string]cron.EntryID
+	paused     map[string]bool
 }
 
-func NewSweeperAgent(configPath string) (*SweeperAgent, error) {
-	config, err := utils.ReadConfig(configPath)
+// NewSweeperAgent reads the config at configPath. clients resolves the Prime
+// client of every portfolio the config declares. Every client shares a
+// single rate limiter.
+func NewSweeperAgent(configPath string, clients utils.ClientResolver) (*SweeperAgent, error) {
+	return newSweeperAgent(configPath, clients, store.Open, false)
+}
+
+// NewCommandAgent returns an agent for a one-off command. It never resumes
+// transfers, tracks the transfers it submits or writes a shutdown handoff; the
+// daemon picks those transfers up on its next start. With readOnly the ledger
+// is only read, which is all a dry run needs.
+func NewCommandAgent(configPath string, clients utils.ClientResolver, readOnly bool) (*SweeperAgent, error) {
+	openLedger := store.Open
+	if readOnly {
+		openLedger = store.OpenReadOnly
+	}
+	return newSweeperAgent(configPath, clients, openLedger, true)
+}
+
+func newSweeperAgent(
+	configPath string,
+	clients utils.ClientResolver,
+	openLedger func(path string) (*store.Ledger, error),
+	oneShot bool,
+) (*SweeperAgent, error) {
+	limiter := utils.NewRateLimiter(utils.GetRateLimit(&model.Config{}))
+	clients = instrumentClients(clients, limiter)
+
+	config, err := utils.ReadConfig(configPath, clients)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
+	limiter.SetLimit(utils.GetRateLimit(config))
+
+	ledger, err := openLedger(utils.GetLedgerPath(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
 
 	return &SweeperAgent{
-		config: config,
-		cron:   cron.New(cron.WithSeconds()),
+		oneShot:    oneShot,
+		clients:    clients,
+		limiter:    limiter,
+		configPath: configPath,
+		config:     config,
+		reloaded:   make(chan struct{}),
+		portfolios: make(map[string]*core.Portfolio),
+		cron:       cron.New(cron.WithParser(utils.ScheduleParser)),
+		ledger:     ledger,
+		runs:       NewRunGuard(),
+		tracker:    core.NewTracker(),
+		entries:    make(map[string]cron.EntryID),
+		paused:     make(map[string]bool),
 	}, nil
 }
 
+func instrumentClients(clients utils.ClientResolver, limiter *utils.RateLimiter) utils.ClientResolver {
+	return func(portfolio model.Portfolio) (utils.PrimeClient, error) {
+		client, err := clients(portfolio)
+		if err != nil {
+			return nil, err
+		}
+		return utils.NewRateLimitedClient(metrics.InstrumentClient(client), limiter), nil
+	}
+}
+
+func (a *SweeperAgent) getConfig() *model.Config {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.config
+}
+
+// configReloaded returns a channel closed at the next successful reload.
+func (a *SweeperAgent) configReloaded() <-chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.reloaded
+}
+
+// ruleScope returns the portfolio a rule runs in together with the config
+// restricted to that portfolio.
+func (a *SweeperAgent) ruleScope(rule model.Rule) (*core.Portfolio, *model.Config, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	portfolioName := utils.RulePortfolio(a.config, rule)
+	portfolio, exists := a.portfolios[portfolioName]
+	if !exists {
+		return nil, nil, fmt.Errorf("portfolio '%s' of rule '%s' is not set up", portfolioName, rule.Name)
+	}
+	return portfolio, utils.ScopeConfig(a.config, portfolioName), nil
+}
+
+// buildPortfolios resolves the client of every portfolio in config through
+// clients and collects its trading wallets.
+func (a *SweeperAgent) buildPortfolios(config *model.Config, clients utils.ClientResolver) (map[string]*core.Portfolio, error) {
+	portfolios := make(map[string]*core.Portfolio)
+	for _, portfolioConfig := range utils.GetPortfolios(config) {
+		client, err := clients(portfolioConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get client for portfolio '%s': %w", portfolioConfig.Name, err)
+		}
+		client = utils.NewRetryingClient(client, utils.GetRetryPolicy(config))
+
+		portfolio, err := core.NewPortfolio(portfolioConfig.Name, client, utils.ScopeConfig(config, portfolioConfig.Name))
+		if err != nil {
+			return nil, fmt.Errorf("cannot collect trading wallets for portfolio '%s': %w", portfolioConfig.Name, err)
+		}
+		portfolios[portfolio.Name] = portfolio
+	}
+	return portfolios, nil
+}
+
+// Load collects the trading wallets of every portfolio, which is all that
+// Plan needs. Setup calls it.
+func (a *SweeperAgent) Load() error {
+	portfolios, err := a.buildPortfolios(a.getConfig(), a.clients)
+	if err != nil {
+		return err
+	}
+	for _, portfolio := range portfolios {
+		zap.L().Info("successfully collected trading wallet information.",
+			zap.String("portfolio", portfolio.Name),
+			zap.Any("TradingWallets", portfolio.TradingWallets),
+		)
+	}
+
+	a.mu.Lock()
+	a.portfolios = portfolios
+	a.mu.Unlock()
+	return nil
+}
+
+// Setup loads the portfolios, enables notifications and, unless the agent runs
+// a one-off command or in dry run mode, resumes the transfers left in flight
+// by a previous run.
 func (a *SweeperAgent) Setup() error {
-	var err error
-	core.TradingWallets, err = core.CollectTradingWallets(a.config)
+	if err := a.Load(); err != nil {
+		return err
+	}
+	config := a.getConfig()
+
+	notifier, err := notify.FromConfig(config.Notifications)
 	if err != nil {
-		return fmt.Errorf("cannot collect trading wallets: %w", err)
+		return fmt.Errorf("cannot set up notifications: %w", err)
+	}
+	notify.SetNotifier(notifier)
+
+	if a.oneShot {
+		// Submitted transfers are left in the ledger for the daemon to
+		// track on its next start.
+		_ = a.tracker.Stop(context.Background())
+		core.SetTracker(a.tracker)
+		return nil
+	}
+
+	a.mu.Lock()
+	portfolios := a.portfolios
+	a.mu.Unlock()
+
+	handoff, err := a.ledger.ReadHandoff()
+	if err != nil {
+		zap.L().Error("cannot read shutdown handoff", zap.Error(err))
+	} else if handoff != nil {
+		zap.L().Info("resuming transfers handed off at last shutdown",
+			zap.Time("written_at", handoff.WrittenAt),
+			zap.Int("transfers", len(handoff.Transfers)),
+		)
+	}
+
+	core.SetTracker(a.tracker)
+	if config.Daemon.DryRun {
+		zap.L().Info("dry run, transfers left in flight are not resumed")
+		return nil
+	}
+
+	for _, portfolio := range portfolios {
+		scoped := utils.ScopeConfig(config, portfolio.Name)
+		if err := core.ResumeTransfers(portfolio.Client, a.ledger, scoped); err != nil {
+			return fmt.Errorf("cannot resume transfers for portfolio '%s': %w", portfolio.Name, err)
+		}
 	}
-	zap.L().Info("successfully collected trading wallet information.",
-		zap.Any("TradingWallets", core.TradingWallets),
-	)
 
 	return nil
 }
 
+// Run schedules every rule and blocks until a signal other than SIGHUP is
+// received on stopChan. SIGHUP reloads the config file.
 func (a *SweeperAgent) Run(stopChan <-chan os.Signal) error {
-	var wg sync.WaitGroup
+	if err := a.scheduleRules(); err != nil {
+		return err
+	}
+
+	config := a.getConfig()
+
+	adminServer, err := startServer("admin", config.Daemon.AdminAddress, a.AdminHandler())
+	if err != nil {
+		return err
+	}
+
+	metricsServer, err := startServer("metrics", config.Daemon.MetricsAddress, metricsHandler())
+	if err != nil {
+		stopServer("admin", adminServer)
+		return err
+	}
+
+	done := make(chan struct{})
+	a.jobs.Add(2)
+	go a.watchThresholds(done, &a.jobs)
+	go a.watchConfigFile(done, &a.jobs)
+
+	a.cron.Start()
+
+	for signal := range stopChan {
+		if signal != syscall.SIGHUP {
+			break
+		}
+		_ = a.Reload()
+	}
+
+	close(done)
+	stopServer("admin", adminServer)
+	stopServer("metrics", metricsServer)
+	a.shutdown()
+
+	return a.ledger.Close()
+}
+
+// Close ends a one-off command that did not call Run: it waits for pending
+// notifications and closes the ledger.
+func (a *SweeperAgent) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout())
+	defer cancel()
+
+	if err := notify.Flush(ctx); err != nil {
+		zap.L().Warn("notifications were not delivered before the shutdown timeout", zap.Error(err))
+	}
+	return a.ledger.Close()
+}
+
+func (a *SweeperAgent) shutdownTimeout() time.Duration {
+	if timeout := a.getConfig().Daemon.ShutdownTimeout; timeout > 0 {
+		return timeout.Duration()
+	}
+	return defaultShutdownTimeout.Duration()
+}
+
+// shutdown stops scheduling new runs, gives running rule executions until the
+// shutdown timeout to finish, then stops every transfer tracker, writes a
+// handoff of the transfers still in flight and waits for pending
+// notifications.
+func (a *SweeperAgent) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout())
+	defer cancel()
+
+	cronDone := a.Stop()
+	jobsDone := make(chan struct{})
+	go func() {
+		<-cronDone.Done()
+		a.jobs.Wait()
+		close(jobsDone)
+	}()
+
+	select {
+	case <-jobsDone:
+		zap.L().Info("all rule executions finished")
+	case <-ctx.Done():
+		zap.L().Warn("rule executions did not finish before the shutdown timeout")
+	}
+
+	if err := a.tracker.Stop(ctx); err != nil {
+		zap.L().Warn("transfer trackers did not stop before the shutdown timeout", zap.Error(err))
+	}
+
+	defer func() {
+		if err := notify.Flush(ctx); err != nil {
+			zap.L().Warn("notifications were not delivered before the shutdown timeout", zap.Error(err))
+		}
+	}()
+
+	handoff, err := a.ledger.WriteHandoff()
+	if err != nil {
+		zap.L().Error("cannot write shutdown handoff", zap.Error(err))
+		return
+	}
+	for _, record := range handoff.Transfers {
+		zap.L().Info("transfer in flight at shutdown",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("rule", record.RuleName),
+			zap.String("status", record.Status),
+			zap.String("transaction_id", record.TransactionId),
+			zap.String("approval_url", record.ApprovalUrl),
+		)
+	}
+	zap.L().Info("wrote shutdown handoff", zap.Int("transfers", len(handoff.Transfers)))
+}
+
+func metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+func (a *SweeperAgent) scheduleRules() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	schedules, err := parseSchedules(a.config.Rules)
+	if err != nil {
+		return err
+	}
 
 	for _, rule := range a.config.Rules {
-		rule := rule
-		_, err := a.cron.AddFunc(rule.Schedule, func() {
-			wg.Add(1)
-			defer wg.Done()
-
-			transferDetails := model.TransferDetails{
-				Direction:   model.TransferDirection(rule.Direction),
-				WalletNames: rule.Wallets,
-				OperationId: uuid.New().String(),
-				RuleName:    rule.Name,
-			}
-			core.ProcessTransfers(a.config, rule, transferDetails)
-		})
+		if schedule, exists := schedules[rule.Name]; exists {
+			a.entries[rule.Name] = a.scheduleRule(rule, schedule)
+		}
+	}
+
+	return nil
+}
+
+// parseSchedules parses the schedule of every scheduled rule, so that a bad
+// expression is caught before any cron entry is changed.
+func parseSchedules(rules []model.Rule) (map[string]cron.Schedule, error) {
+	schedules := make(map[string]cron.Schedule)
+	for _, rule := range rules {
+		if rule.Schedule == "" {
+			continue
+		}
+
+		schedule, err := utils.ScheduleParser.Parse(rule.Schedule)
 		if err != nil {
 			zap.L().Error("failed to schedule cron job for rule", zap.Any("rule", rule), zap.Error(err))
-			return err
+			return nil, fmt.Errorf("invalid schedule for rule '%s': %w", rule.Name, err)
 		}
+		schedules[rule.Name] = schedule
 	}
+	return schedules, nil
+}
 
-	a.cron.Start()
+func (a *SweeperAgent) scheduleRule(rule model.Rule, schedule cron.Schedule) cron.EntryID {
+	var entryId cron.EntryID
+	// Running jobs are awaited through cron.Stop on shutdown rather than
+	// a.jobs, which must not be added to once shutdown has started waiting.
+	entryId = a.cron.Schedule(schedule, cron.FuncJob(func() {
+		if a.isPaused(rule.Name) {
+			zap.L().Info("rule is paused, skipping scheduled run", zap.String("rule", rule.Name))
+			return
+		}
+
+		// Prev holds the time this tick was scheduled for, not when it started.
+		a.executeRule(rule, a.cron.Entry(entryId).Prev, nil)
+	}))
+	return entryId
+}
+
+func (a *SweeperAgent) isPaused(ruleName string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.paused[ruleName]
+}
+
+func (a *SweeperAgent) setPaused(ruleName string, paused bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.paused[ruleName] = paused
+}
+
+func (a *SweeperAgent) findRule(ruleName string) (model.Rule, bool) {
+	for _, rule := range a.getConfig().Rules {
+		if rule.Name == ruleName {
+			return rule, true
+		}
+	}
+	return model.Rule{}, false
+}
 
-	<-stopChan
+// executeRule runs rule once for the tick scheduled at scheduledAt. When assets
+// is set, a hot-to-cold sweep is restricted to those assets. It reports whether
+// the run happened and completed; skipped and failed runs return false.
+func (a *SweeperAgent) executeRule(rule model.Rule, scheduledAt time.Time, assets []string) bool {
+	portfolio, config, err := a.ruleScope(rule)
+	if err != nil {
+		zap.L().Error("cannot execute rule", zap.String("rule", rule.Name), zap.Error(err))
+		return false
+	}
 
-	a.Stop()
-	wg.Wait()
+	done, started := a.runs.Start(rule.Name, core.RuleWalletIds(portfolio, config, rule))
+	if !started {
+		zap.L().Warn("previous run of rule is still in progress, skipping",
+			zap.String("rule", rule.Name),
+			zap.Time("scheduled_at", scheduledAt),
+		)
+		metrics.RuleRunsSkipped.WithLabelValues(rule.Name).Inc()
+		notify.Send(notify.Event{
+			Type:     notify.EventRuleSkipped,
+			Severity: notify.SeverityWarning,
+			RuleName: rule.Name,
+			Message:  fmt.Sprintf("run scheduled at %s skipped, previous run still in progress", scheduledAt.Format(time.RFC3339)),
+		})
+		return false
+	}
+	defer done()
+
+	transferDetails := model.TransferDetails{
+		Direction:   model.TransferDirection(rule.Direction),
+		WalletNames: rule.Wallets,
+		OperationId: uuid.New().String(),
+		RuleName:    rule.Name,
+		ScheduledAt: scheduledAt,
+		DryRun:      config.Daemon.DryRun,
+		Assets:      assets,
+	}
+	_, err = core.ProcessTransfers(portfolio, a.ledger, config, rule, transferDetails)
+	return err == nil
+}
 
+// RunRule executes the named rule once, as if it had been triggered through
+// the admin API.
+func (a *SweeperAgent) RunRule(ruleName string) error {
+	rule, exists := a.findRule(ruleName)
+	if !exists {
+		return fmt.Errorf("rule '%s' not found", ruleName)
+	}
+	a.executeRule(rule, time.Now().Truncate(time.Second), nil)
 	return nil
 }
 
-func (a *SweeperAgent) Stop() {
-	a.cron.Stop()
+// Plan runs every rule once in dry-run mode and returns the transfers that
+// would have been submitted.
+func (a *SweeperAgent) Plan() []core.PlannedTransfer {
+	var plan []core.PlannedTransfer
+	for _, rule := range a.getConfig().Rules {
+		portfolio, config, err := a.ruleScope(rule)
+		if err != nil {
+			zap.L().Error("cannot plan rule", zap.String("rule", rule.Name), zap.Error(err))
+			continue
+		}
+
+		transferDetails := model.TransferDetails{
+			Direction:   model.TransferDirection(rule.Direction),
+			WalletNames: rule.Wallets,
+			OperationId: uuid.New().String(),
+			RuleName:    rule.Name,
+			ScheduledAt: time.Now().Truncate(time.Second),
+			DryRun:      true,
+		}
+		// Failures are logged; the plan holds what could be planned.
+		rulePlan, _ := core.ProcessTransfers(portfolio, a.ledger, config, rule, transferDetails)
+		plan = append(plan, rulePlan...)
+	}
+	return plan
+}
+
+// Stop stops scheduling rule runs. The returned context is done once the
+// runs already started have returned.
+func (a *SweeperAgent) Stop() context.Context {
+	ctx := a.cron.Stop()
 	zap.L().Info("cron scheduler stopped, waiting for all jobs to complete.")
+	return ctx
 }
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"go.uber.org/zap"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const defaultConfigReloadFrequency model.Seconds = 5
+
+// Reload re-reads and validates the config file and applies rule changes to
+// the scheduler. If the new config is invalid the current config is kept and
+// the error is returned. The threshold and config poll frequencies apply from
+// the reload on, the transfer monitor settings to transfers submitted after
+// it. Changes to listen addresses and the ledger path only take effect after a
+// restart.
+func (a *SweeperAgent) Reload() error {
+	// Validation and the new portfolios share one client per portfolio.
+	clients := utils.CachedClients(a.clients)
+	newConfig, err := utils.ReadConfig(a.configPath, clients)
+	if err != nil {
+		zap.L().Error("config reload rejected, keeping current config", zap.Error(err))
+		return fmt.Errorf("config reload rejected: %w", err)
+	}
+
+	schedules, err := parseSchedules(newConfig.Rules)
+	if err != nil {
+		zap.L().Error("config reload rejected, keeping current config", zap.Error(err))
+		return fmt.Errorf("config reload rejected: %w", err)
+	}
+
+	portfolios, err := a.buildPortfolios(newConfig, clients)
+	if err != nil {
+		zap.L().Error("config reload rejected, keeping current config", zap.Error(err))
+		return fmt.Errorf("config reload rejected: %w", err)
+	}
+
+	notifier, err := notify.FromConfig(newConfig.Notifications)
+	if err != nil {
+		zap.L().Error("config reload rejected, keeping current config", zap.Error(err))
+		return fmt.Errorf("config reload rejected: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oldRules := make(map[string]model.Rule)
+	for _, rule := range a.config.Rules {
+		oldRules[rule.Name] = rule
+	}
+	newRules := make(map[string]model.Rule)
+	for _, rule := range newConfig.Rules {
+		newRules[rule.Name] = rule
+	}
+
+	var added, removed, replaced []string
+	for name, oldRule := range oldRules {
+		newRule, exists := newRules[name]
+		if exists && reflect.DeepEqual(oldRule, newRule) {
+			continue
+		}
+
+		if entryId, scheduled := a.entries[name]; scheduled {
+			a.cron.Remove(entryId)
+			delete(a.entries, name)
+		}
+		if exists {
+			replaced = append(replaced, name)
+		} else {
+			removed = append(removed, name)
+			delete(a.paused, name)
+		}
+	}
+
+	for _, rule := range newConfig.Rules {
+		oldRule, existed := oldRules[rule.Name]
+		if existed && reflect.DeepEqual(oldRule, rule) {
+			continue
+		}
+		if !existed {
+			added = append(added, rule.Name)
+		}
+		if schedule, exists := schedules[rule.Name]; exists {
+			a.entries[rule.Name] = a.scheduleRule(rule, schedule)
+		}
+	}
+
+	if a.config.Daemon.AdminAddress != newConfig.Daemon.AdminAddress ||
+		a.config.Daemon.MetricsAddress != newConfig.Daemon.MetricsAddress ||
+		a.config.Daemon.LedgerPath != newConfig.Daemon.LedgerPath {
+		zap.L().Warn("changes to listen addresses or the ledger path require a restart")
+	}
+
+	a.portfolios = portfolios
+	a.config = newConfig
+	notify.SetNotifier(notifier)
+	a.limiter.SetLimit(utils.GetRateLimit(newConfig))
+	close(a.reloaded)
+	a.reloaded = make(chan struct{})
+
+	zap.L().Info("config reloaded",
+		zap.Strings("added_rules", added),
+		zap.Strings("removed_rules", removed),
+		zap.Strings("replaced_rules", replaced),
+	)
+	return nil
+}
+
+func (a *SweeperAgent) configReloadFrequency() time.Duration {
+	if frequency := a.getConfig().Daemon.ConfigReloadFrequency; frequency > 0 {
+		return frequency.Duration()
+	}
+	return defaultConfigReloadFrequency.Duration()
+}
+
+// watchConfigFile reloads the config whenever the content of the config file
+// changes.
+func (a *SweeperAgent) watchConfigFile(done <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	lastHash, err := hashFile(a.configPath)
+	if err != nil {
+		zap.L().Error("cannot read config file, not watching for changes", zap.Error(err))
+		return
+	}
+
+	reloaded := a.configReloaded()
+	frequency := a.configReloadFrequency()
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-reloaded:
+			reloaded = a.configReloaded()
+			if next := a.configReloadFrequency(); next != frequency {
+				frequency = next
+				ticker.Reset(frequency)
+			}
+		case <-ticker.C:
+			hash, err := hashFile(a.configPath)
+			if err != nil {
+				zap.L().Error("cannot read config file", zap.Error(err))
+				continue
+			}
+			if bytes.Equal(hash, lastHash) {
+				continue
+			}
+
+			// A rejected config is not retried until the file changes again.
+			lastHash = hash
+			zap.L().Info("config file changed, reloading", zap.String("path", a.configPath))
+			_ = a.Reload()
+		}
+	}
+}
+
+func hashFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
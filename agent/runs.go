@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// RunGuard keeps a rule from overlapping with itself and serializes rules that
+// touch the same wallets. A run of a rule that is still running is skipped;
+// a run that needs a wallet held by another rule waits for it.
+type RunGuard struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running map[string]bool
+	wallets map[string]string
+	skipped map[string]skippedRuns
+}
+
+type skippedRuns struct {
+	Count int
+	Last  time.Time
+}
+
+func NewRunGuard() *RunGuard {
+	g := &RunGuard{
+		running: make(map[string]bool),
+		wallets: make(map[string]string),
+		skipped: make(map[string]skippedRuns),
+	}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Start marks ruleName as running and takes the locks of walletIds, waiting
+// for other rules to release them. It returns false, and records the skip,
+// when the rule is already running. The returned function ends the run.
+func (g *RunGuard) Start(ruleName string, walletIds []string) (func(), bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running[ruleName] {
+		skipped := g.skipped[ruleName]
+		skipped.Count++
+		skipped.Last = time.Now()
+		g.skipped[ruleName] = skipped
+		return nil, false
+	}
+	g.running[ruleName] = true
+
+	// Wallets are taken all at once, so rules waiting on each other's
+	// wallets cannot deadlock.
+	walletIds = append([]string(nil), walletIds...)
+	for !g.walletsFree(walletIds) {
+		g.cond.Wait()
+	}
+	for _, walletId := range walletIds {
+		g.wallets[walletId] = ruleName
+	}
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		for _, walletId := range walletIds {
+			delete(g.wallets, walletId)
+		}
+		delete(g.running, ruleName)
+		g.cond.Broadcast()
+	}, true
+}
+
+// WalletsFree reports whether no running rule holds any of walletIds.
+func (g *RunGuard) WalletsFree(walletIds []string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.walletsFree(walletIds)
+}
+
+func (g *RunGuard) walletsFree(walletIds []string) bool {
+	for _, walletId := range walletIds {
+		if _, held := g.wallets[walletId]; held {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRunning reports whether a run of ruleName has started and not ended.
+func (g *RunGuard) IsRunning(ruleName string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.running[ruleName]
+}
+
+func (g *RunGuard) skippedRuns(ruleName string) skippedRuns {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.skipped[ruleName]
+}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"time"
+)
+
+const serverShutdownTimeout = 5 * time.Second
+
+// startServer serves handler on address in the background. An empty address
+// disables the server and returns nil.
+func startServer(name, address string, handler http.Handler) (*http.Server, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s address %s: %w", name, address, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("server stopped", zap.String("server", name), zap.Error(err))
+		}
+	}()
+
+	zap.L().Info("server listening", zap.String("server", name), zap.String("address", listener.Addr().String()))
+	return server, nil
+}
+
+func stopServer(name string, server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		zap.L().Error("failed to shut down server", zap.String("server", name), zap.Error(err))
+	}
+}
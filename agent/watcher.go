@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const defaultThresholdPollFrequency model.Seconds = 30
+
+func (a *SweeperAgent) thresholdPollFrequency() time.Duration {
+	if frequency := a.getConfig().Daemon.ThresholdPollFrequency; frequency > 0 {
+		return frequency.Duration()
+	}
+	return defaultThresholdPollFrequency.Duration()
+}
+
+// watchThresholds polls the trading balances of every rule with thresholds and
+// executes the rule for the assets whose balance crossed from below to at or
+// above their threshold; the rule's other assets are left alone. Runs are
+// dispatched like cron runs, so a slow run does not hold up the other rules.
+// An asset stays marked above only if its run completed, so a skipped or failed
+// run is retried on the next tick. Rules are read from the current config on
+// every tick, and the poll frequency on every reload, so that reloads take
+// effect.
+func (a *SweeperAgent) watchThresholds(done <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	reloaded := a.configReloaded()
+	frequency := a.thresholdPollFrequency()
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	above := &thresholdMarks{assets: make(map[string]map[string]bool)}
+	for {
+		select {
+		case <-done:
+			return
+		case <-reloaded:
+			reloaded = a.configReloaded()
+			if next := a.thresholdPollFrequency(); next != frequency {
+				frequency = next
+				ticker.Reset(frequency)
+			}
+		case tick := <-ticker.C:
+			for _, rule := range a.getConfig().Rules {
+				if len(rule.Thresholds) == 0 || a.isPaused(rule.Name) {
+					continue
+				}
+				a.checkThresholds(rule, tick.Truncate(time.Second), above)
+			}
+		}
+	}
+}
+
+// thresholdMarks holds, per rule, the assets last seen at or above their
+// threshold. Runs clear the marks of the assets they failed to sweep.
+type thresholdMarks struct {
+	mu     sync.Mutex
+	assets map[string]map[string]bool
+}
+
+// update marks assets as the ones now above their threshold for ruleName and
+// returns those that were not marked before.
+func (m *thresholdMarks) update(ruleName string, assets []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var crossed []string
+	current := make(map[string]bool)
+	for _, asset := range assets {
+		if !m.assets[ruleName][asset] {
+			crossed = append(crossed, asset)
+		}
+		current[asset] = true
+	}
+	m.assets[ruleName] = current
+	return crossed
+}
+
+func (m *thresholdMarks) clear(ruleName string, assets []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, asset := range assets {
+		delete(m.assets[ruleName], asset)
+	}
+}
+
+func (a *SweeperAgent) checkThresholds(rule model.Rule, checkedAt time.Time, above *thresholdMarks) {
+	portfolio, config, err := a.ruleScope(rule)
+	if err != nil {
+		zap.L().Error("cannot check thresholds", zap.String("rule", rule.Name), zap.Error(err))
+		return
+	}
+
+	balances, err := core.CollectThresholdBalances(portfolio, config, rule)
+	if err != nil {
+		zap.L().Error("failed to query balances for thresholds", zap.Any("rule", rule), zap.Error(err))
+		return
+	}
+
+	assets, err := core.AssetsAboveThreshold(rule, balances)
+	if err != nil {
+		zap.L().Error("failed to evaluate thresholds", zap.Any("rule", rule), zap.Error(err))
+		return
+	}
+
+	crossed := above.update(rule.Name, assets)
+	if len(crossed) == 0 {
+		return
+	}
+
+	zap.L().Info("balance threshold crossed, executing rule for crossed assets",
+		zap.String("rule", rule.Name),
+		zap.Strings("assets", crossed),
+	)
+	// The watcher is itself one of a.jobs, so adding to it cannot race with
+	// shutdown waiting for them.
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		if !a.executeRule(rule, checkedAt, crossed) {
+			above.clear(rule.Name, crossed)
+		}
+	}()
+}
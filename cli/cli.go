@@ -0,0 +1,160 @@
+// Package cli implements the sweeper command line.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(options *options, args []string) error
+	// flags registers the command's own flags, if any.
+	flags func(options *options)
+}
+
+var commands = []command{
+	{name: "run", summary: "run the sweeper daemon", run: runDaemon},
+	{name: "validate", summary: "validate the config file, reporting every problem", run: validate, flags: validateFlags},
+	{name: "plan", summary: "print the transfers every rule would submit now, without submitting", run: plan},
+	{name: "run-rule", args: "<name>", summary: "execute a single rule once", run: runRule},
+	{name: "export-wallets", summary: "export a portfolio's vault wallets and balances to CSV", run: exportWallets, flags: exportWalletsFlags},
+	{name: "status", summary: "show rules and in-flight transfers", run: status},
+}
+
+// options holds the flags shared by every command.
+type options struct {
+	configPath  string
+	logLevel    string
+	credentials string
+	flags       *flag.FlagSet
+
+	// validate only.
+	online bool
+
+	// export-wallets only.
+	portfolio string
+	output    string
+}
+
+func (o *options) clients() (utils.ClientResolver, error) {
+	switch {
+	case o.credentials == "config", o.credentials == "env":
+		return utils.GetClientForPortfolio, nil
+	case strings.HasPrefix(o.credentials, "file:"):
+		return utils.FileClient(strings.TrimPrefix(o.credentials, "file:")), nil
+	default:
+		return nil, fmt.Errorf("unknown credentials source '%s', expected 'config' or 'file:<path>'", o.credentials)
+	}
+}
+
+// Main runs the command named by args[0] and returns the process exit code.
+// Without a command, or when args start with a flag, the daemon is run.
+func Main(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage(os.Stdout)
+		return exitOk
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", name)
+		usage(os.Stderr)
+		return exitUsage
+	}
+
+	opts := &options{flags: flag.NewFlagSet(cmd.name, flag.ContinueOnError)}
+	opts.flags.StringVar(&opts.configPath, "config", "config.yaml", "path to the config file")
+	opts.flags.StringVar(&opts.logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	opts.flags.StringVar(&opts.credentials, "credentials", "config",
+		"credentials source: 'config' to use each portfolio's configured source, or 'file:<path>' for a JSON credentials file")
+	if cmd.flags != nil {
+		cmd.flags(opts)
+	}
+	opts.flags.Usage = func() {
+		fmt.Fprintf(opts.flags.Output(), "usage: sweeper %s [flags] %s\n\n", cmd.name, cmd.args)
+		opts.flags.PrintDefaults()
+	}
+	if err := opts.flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOk
+		}
+		return exitUsage
+	}
+
+	log, err := newLogger(opts.logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	zap.ReplaceGlobals(log)
+	defer log.Sync()
+
+	if err := cmd.run(opts, opts.flags.Args()); err != nil {
+		if _, isUsage := err.(usageError); isUsage {
+			fmt.Fprintln(os.Stderr, err)
+			opts.flags.Usage()
+			return exitUsage
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		return exitError
+	}
+	return exitOk
+}
+
+// usageError reports bad command line arguments.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sweeper <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-26s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'sweeper <command> -h' for the flags of a command.")
+}
+
+func newLogger(level string) (*zap.Logger, error) {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level '%s': %w", level, err)
+	}
+
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(parsed)
+	log, err := config.Build()
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize logger: %w", err)
+	}
+	return log, nil
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func newCommandAgent(opts *options, readOnly bool) (*agent.SweeperAgent, error) {
+	clients, err := opts.clients()
+	if err != nil {
+		return nil, err
+	}
+	return agent.NewCommandAgent(opts.configPath, clients, readOnly)
+}
+
+func runDaemon(opts *options, args []string) error {
+	if len(args) > 0 {
+		return usageError("run takes no arguments")
+	}
+
+	clients, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	sweeperAgent, err := agent.NewSweeperAgent(opts.configPath, clients)
+	if err != nil {
+		return fmt.Errorf("failed to initialize sweeper agent: %w", err)
+	}
+
+	if err := sweeperAgent.Setup(); err != nil {
+		return fmt.Errorf("failed to setup sweeper agent: %w", err)
+	}
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	if err := sweeperAgent.Run(stopChan); err != nil {
+		return fmt.Errorf("error running sweeper agent: %w", err)
+	}
+
+	zap.L().Info("Sweeper shut down gracefully.")
+	return nil
+}
+
+func validateFlags(opts *options) {
+	opts.flags.BoolVar(&opts.online, "online", false,
+		"also check every wallet against Prime, which needs credentials")
+}
+
+// validate checks the config without Prime access unless -online is set, and
+// prints every problem found.
+func validate(opts *options, args []string) error {
+	if len(args) > 0 {
+		return usageError("validate takes no arguments")
+	}
+
+	var clients utils.ClientResolver
+	if opts.online {
+		var err error
+		if clients, err = opts.clients(); err != nil {
+			return err
+		}
+	}
+
+	_, err := utils.ValidateConfig(opts.configPath, clients)
+	var invalid *utils.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			fmt.Printf("%s:%s\n", opts.configPath, problemLocation(problem))
+		}
+		return fmt.Errorf("%d problems found in %s", len(invalid.Problems), opts.configPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid\n", opts.configPath)
+	return nil
+}
+
+// problemLocation formats a problem the way compilers do, so that editors
+// can jump to the line.
+func problemLocation(problem utils.Problem) string {
+	if problem.Line > 0 {
+		return fmt.Sprintf("%d: %s: %s", problem.Line, problem.Path, problem.Message)
+	}
+	return fmt.Sprintf(" %s: %s", problem.Path, problem.Message)
+}
+
+func plan(opts *options, args []string) error {
+	if len(args) > 0 {
+		return usageError("plan takes no arguments")
+	}
+
+	// Planning only reads the ledger, so it never changes what the daemon
+	// will do.
+	sweeperAgent, err := newCommandAgent(opts, true)
+	if err != nil {
+		return err
+	}
+	defer sweeperAgent.Close()
+
+	if err := sweeperAgent.Load(); err != nil {
+		return err
+	}
+	return printJSON(sweeperAgent.Plan())
+}
+
+func runRule(opts *options, args []string) error {
+	if len(args) != 1 {
+		return usageError("run-rule takes exactly one rule name")
+	}
+
+	sweeperAgent, err := newCommandAgent(opts, false)
+	if err != nil {
+		return err
+	}
+
+	if err := sweeperAgent.Setup(); err != nil {
+		sweeperAgent.Close()
+		return err
+	}
+
+	// Transfers the rule submits are recorded in the ledger; the daemon
+	// tracks them and submits any queued chunks on its next start.
+	runErr := sweeperAgent.RunRule(args[0])
+	if err := sweeperAgent.Close(); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
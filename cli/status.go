@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"net/http"
+	"os"
+	"time"
+)
+
+const statusRequestTimeout = 5 * time.Second
+
+type statusReport struct {
+	Source    string                 `json:"source"`
+	Rules     json.RawMessage        `json:"rules,omitempty"`
+	Transfers []store.TransferRecord `json:"transfers"`
+	Handoff   *store.Handoff         `json:"handoff,omitempty"`
+}
+
+// status asks the running daemon through its admin API. When no admin address
+// is configured, it reads the ledger directly, which only works while the
+// daemon is stopped.
+func status(opts *options, args []string) error {
+	if len(args) > 0 {
+		return usageError("status takes no arguments")
+	}
+
+	config, err := utils.LoadConfig(opts.configPath)
+	if err != nil {
+		return err
+	}
+
+	if address := config.Daemon.AdminAddress; address != "" {
+		report := statusReport{Source: "admin_api"}
+		if err := getAdmin(address, "/rules", &report.Rules); err != nil {
+			return err
+		}
+		if err := getAdmin(address, "/transfers", &report.Transfers); err != nil {
+			return err
+		}
+		return printJSON(report)
+	}
+
+	ledger, err := store.OpenReadOnly(utils.GetLedgerPath(config))
+	if err != nil {
+		return fmt.Errorf("%w (set daemon.admin_address to query a running sweeper)", err)
+	}
+	defer ledger.Close()
+
+	report := statusReport{Source: "ledger"}
+	if report.Transfers, err = ledger.NonTerminal(); err != nil {
+		return err
+	}
+	if report.Handoff, err = ledger.ReadHandoff(); err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+func getAdmin(address, path string, target interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, "http://"+address+path, nil)
+	if err != nil {
+		return err
+	}
+	if token := os.Getenv(agent.AdminTokenEnv); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: statusRequestTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("cannot reach admin API at %s: %w", address, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("admin API returned %s for %s", response.Status, path)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// WalletRow is one line of the exported wallets CSV.
+type WalletRow struct {
+	Name    string
+	Id      string
+	Symbol  string
+	Balance string
+}
+
+func exportWalletsFlags(opts *options) {
+	opts.flags.StringVar(&opts.portfolio, "portfolio", "",
+		"portfolio to export; required when the config declares several")
+	opts.flags.StringVar(&opts.output, "output", "",
+		"CSV file to write (defaults to cold_wallets_<portfolio id>_<timestamp>.csv)")
+}
+
+// exportWallets writes every vault wallet of a portfolio with its balance to
+// a CSV file, to help fill in the wallets section of the config. The config
+// file is optional and only used to find the portfolio's credentials.
+func exportWallets(opts *options, args []string) error {
+	if len(args) > 0 {
+		return usageError("export-wallets takes no arguments")
+	}
+
+	portfolio, err := exportPortfolio(opts)
+	if err != nil {
+		return err
+	}
+	clients, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	client, err := clients(portfolio)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	var allWallets []WalletRow
+	cursor := ""
+
+	for {
+		request := &prime.ListWalletsRequest{
+			PortfolioId: client.PortfolioId(),
+			Type:        "VAULT",
+			Pagination: &prime.PaginationParams{
+				Cursor:        cursor,
+				Limit:         "1000",
+				SortDirection: "ASC",
+			},
+		}
+
+		ctx := context.Background()
+		response, err := client.ListWallets(ctx, request)
+		if err != nil {
+			return fmt.Errorf("error listing wallets: %w", err)
+		}
+
+		totalWalletCount := len(response.Wallets)
+
+		for i, wallet := range response.Wallets {
+			balanceResponse, err := client.GetWalletBalance(ctx, &prime.GetWalletBalanceRequest{
+				PortfolioId: request.PortfolioId,
+				Id:          wallet.Id,
+			})
+			if err != nil {
+				fmt.Printf("error getting wallet balance for %s: %v\n", wallet.Name, err)
+				continue
+			}
+
+			allWallets = append(allWallets, WalletRow{
+				Name:    wallet.Name,
+				Id:      wallet.Id,
+				Symbol:  wallet.Symbol,
+				Balance: balanceResponse.Balance.WithdrawableAmount,
+			})
+			fmt.Printf("%d/%d: wallet %s (%s) written to csv\n", i+1, totalWalletCount, wallet.Name, wallet.Symbol)
+		}
+
+		if !response.HasNext() {
+			break
+		}
+
+		cursor = response.Pagination.NextCursor
+	}
+
+	sort.Slice(allWallets, func(i, j int) bool {
+		return allWallets[i].Symbol < allWallets[j].Symbol
+	})
+
+	filename := opts.output
+	if filename == "" {
+		timestamp := time.Now().Format("20060102-150405")
+		filename = fmt.Sprintf("cold_wallets_%s_%s.csv", shortId(client.PortfolioId()), timestamp)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating CSV file: %w", err)
+	}
+
+	err = WriteWalletsCsv(file, allWallets)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing CSV file %s: %w", filename, err)
+	}
+
+	fmt.Printf("cold wallets have been successfully exported to %s, sorted by symbol.\n", filename)
+	return nil
+}
+
+// WriteWalletsCsv writes wallets as CSV with a header line. Write errors,
+// including those only reported when the output is flushed, are returned.
+func WriteWalletsCsv(w io.Writer, wallets []WalletRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Name", "ID", "Symbol", "Balance"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+	for _, wallet := range wallets {
+		if err := writer.Write([]string{
+			wallet.Name,
+			wallet.Id,
+			wallet.Symbol,
+			wallet.Balance,
+		}); err != nil {
+			return fmt.Errorf("error writing wallet %s: %w", wallet.Name, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportPortfolio picks the portfolio to export from the config, falling back
+// to the default portfolio when there is no config file.
+func exportPortfolio(opts *options) (model.Portfolio, error) {
+	config, err := utils.LoadConfig(opts.configPath)
+	if os.IsNotExist(err) {
+		config = &model.Config{}
+	} else if err != nil {
+		return model.Portfolio{}, err
+	}
+
+	portfolios := utils.GetPortfolios(config)
+	if opts.portfolio == "" {
+		if len(portfolios) > 1 {
+			return model.Portfolio{}, usageError("the config declares several portfolios, choose one with -portfolio")
+		}
+		return portfolios[0], nil
+	}
+	for _, portfolio := range portfolios {
+		if portfolio.Name == opts.portfolio {
+			return portfolio, nil
+		}
+	}
+	return model.Portfolio{}, fmt.Errorf("portfolio '%s' not found in %s", opts.portfolio, opts.configPath)
+}
+
+// shortId returns the first characters of id, enough to tell portfolios apart
+// in a file name.
+func shortId(id string) string {
+	const length = 5
+	if len(id) <= length {
+		return id
+	}
+	return id[:length]
+}
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// Allocation is the share of a sweep sent to one cold wallet. Part numbers
+// the share for its idempotency key and stays the same for a given
+// destination across re-executions of a tick.
+type Allocation struct {
+	WalletId string
+	Amount   decimal.Decimal
+	Part     int
+}
+
+// coldWalletsForAsset returns the cold custody wallets among walletNames
+// holding symbol, in the order they are listed.
+func coldWalletsForAsset(config *model.Config, walletNames []string, symbol string) []model.Wallet {
+	var wallets []model.Wallet
+	for _, walletName := range walletNames {
+		for _, wallet := range config.Wallets {
+			if wallet.Name == walletName && wallet.Asset == symbol && wallet.Type == model.ColdCustodyWallet {
+				wallets = append(wallets, wallet)
+			}
+		}
+	}
+	return wallets
+}
+
+// destinationWalletNames returns the wallets a sweep of rule may go to: the
+// destination wallets of a cold-to-cold drain, or the rule's wallets.
+func destinationWalletNames(rule model.Rule) []string {
+	if rule.Direction == string(model.ColdToCold) {
+		return rule.DestinationWallets
+	}
+	return rule.Wallets
+}
+
+// allocateSweep splits amount across the cold wallets the rule sweeps into for
+// symbol according to the rule's allocation strategy.
+func allocateSweep(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
+	config *model.Config,
+	rule model.Rule,
+	symbol string,
+	amount decimal.Decimal,
+) ([]Allocation, error) {
+	wallets := coldWalletsForAsset(config, destinationWalletNames(rule), symbol)
+	if len(wallets) == 0 && rule.Direction == string(model.ColdToCold) {
+		return nil, fmt.Errorf("no destination wallet for asset '%s'", symbol)
+	}
+	if len(wallets) == 0 {
+		walletId, err := findColdWalletIdForAsset(config, symbol, model.ColdCustodyWallet)
+		if err != nil {
+			return nil, err
+		}
+		return []Allocation{{WalletId: walletId, Amount: amount}}, nil
+	}
+
+	switch rule.Allocation {
+	case "", model.AllocationFirst:
+		return []Allocation{{WalletId: wallets[0].WalletId, Amount: amount}}, nil
+	case model.AllocationWeighted:
+		return AllocateWeighted(wallets, amount)
+	case model.AllocationFillToCap:
+		balances, err := coldWalletTotals(portfolio, config, wallets)
+		if err != nil {
+			return nil, err
+		}
+		allocations, err := AllocateFillToCap(wallets, balances, amount)
+		if err != nil {
+			return nil, err
+		}
+		if leftover := amount.Sub(sumAllocations(allocations)); leftover.IsPositive() {
+			zap.L().Warn("cold wallets are full, leaving remainder in trading",
+				zap.String("rule", rule.Name),
+				zap.String("symbol", symbol),
+				zap.String("remainder", leftover.String()),
+			)
+		}
+		return allocations, nil
+	case model.AllocationRoundRobin:
+		walletId, err := nextRoundRobinWallet(ledger, rule, symbol, wallets)
+		if err != nil {
+			return nil, err
+		}
+		return []Allocation{{WalletId: walletId, Amount: amount}}, nil
+	case model.AllocationLeastBalance:
+		balances, err := coldWalletBalances(portfolio, config, wallets)
+		if err != nil {
+			return nil, err
+		}
+		return []Allocation{{WalletId: LeastBalanceWallet(wallets, balances), Amount: amount}}, nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy '%s'", rule.Allocation)
+	}
+}
+
+// AllocateWeighted splits amount in proportion to the wallet weights, which
+// default to 1. Shares are truncated to the withdrawal granularity and the
+// rounding remainder goes to the last wallet.
+func AllocateWeighted(wallets []model.Wallet, amount decimal.Decimal) ([]Allocation, error) {
+	weights, total, err := walletWeights(wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocations []Allocation
+	remaining := amount
+	for i, wallet := range wallets {
+		share := remaining
+		if i < len(wallets)-1 {
+			share = amount.Mul(weights[i]).Div(total).Truncate(maxWithdrawalGranularity)
+		}
+		remaining = remaining.Sub(share)
+		if share.GreaterThanOrEqual(minTransactionAmount) {
+			allocations = append(allocations, Allocation{WalletId: wallet.WalletId, Amount: share, Part: i})
+		}
+	}
+	return allocations, nil
+}
+
+// walletWeights returns the weight of each wallet, defaulting to 1, and their
+// sum.
+func walletWeights(wallets []model.Wallet) ([]decimal.Decimal, decimal.Decimal, error) {
+	weights := make([]decimal.Decimal, len(wallets))
+	total := decimal.Zero
+	for i, wallet := range wallets {
+		weights[i] = decimal.NewFromInt(1)
+		if wallet.Weight != "" {
+			weight, err := decimal.NewFromString(wallet.Weight)
+			if err != nil {
+				return nil, decimal.Zero, fmt.Errorf("invalid weight '%s' for wallet '%s': %w", wallet.Weight, wallet.Name, err)
+			}
+			weights[i] = weight
+		}
+		total = total.Add(weights[i])
+	}
+	if !total.IsPositive() {
+		return nil, decimal.Zero, fmt.Errorf("wallet weights must add up to a positive number")
+	}
+	return weights, total, nil
+}
+
+// AllocateFillToCap fills the wallets in order until the total balance of each
+// reaches its cap.
+// Wallets without a cap take whatever is left. Anything that does not fit is
+// not allocated.
+func AllocateFillToCap(wallets []model.Wallet, balances map[string]decimal.Decimal, amount decimal.Decimal) ([]Allocation, error) {
+	var allocations []Allocation
+	remaining := amount
+	for i, wallet := range wallets {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		share := remaining
+		if wallet.Cap != "" {
+			capAmount, err := decimal.NewFromString(wallet.Cap)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cap '%s' for wallet '%s': %w", wallet.Cap, wallet.Name, err)
+			}
+			share = decimal.Min(remaining, capAmount.Sub(balances[wallet.WalletId]))
+		}
+
+		if share.GreaterThanOrEqual(minTransactionAmount) {
+			allocations = append(allocations, Allocation{WalletId: wallet.WalletId, Amount: share, Part: i})
+			remaining = remaining.Sub(share)
+		}
+	}
+	return allocations, nil
+}
+
+// LeastBalanceWallet returns the wallet with the smallest balance. Ties go to
+// the wallet listed first.
+func LeastBalanceWallet(wallets []model.Wallet, balances map[string]decimal.Decimal) string {
+	least := wallets[0]
+	for _, wallet := range wallets[1:] {
+		if balances[wallet.WalletId].LessThan(balances[least.WalletId]) {
+			least = wallet
+		}
+	}
+	return least.WalletId
+}
+
+// nextRoundRobinWallet returns the wallet after the destination of the most
+// recent transfer of the rule for symbol. Deriving the position from the
+// ledger keeps the rotation across restarts.
+func nextRoundRobinWallet(ledger *store.Ledger, rule model.Rule, symbol string, wallets []model.Wallet) (string, error) {
+	records, err := ledger.List()
+	if err != nil {
+		return "", fmt.Errorf("cannot read ledger: %w", err)
+	}
+
+	lastWalletId := ""
+	for _, record := range records {
+		if record.RuleName == rule.Name && record.Request.Symbol == symbol {
+			lastWalletId = record.Request.DestinationWalletId
+		}
+	}
+
+	for i, wallet := range wallets {
+		if wallet.WalletId == lastWalletId {
+			return wallets[(i+1)%len(wallets)].WalletId, nil
+		}
+	}
+	return wallets[0].WalletId, nil
+}
+
+// coldWalletBalances returns the withdrawable balance of each wallet, which is
+// what can be moved out of it.
+func coldWalletBalances(portfolio *Portfolio, config *model.Config, wallets []model.Wallet) (map[string]decimal.Decimal, error) {
+	nonEmpty, err := CollectWalletBalances(portfolio.Client, config, walletIdsOf(wallets))
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]decimal.Decimal)
+	for walletId, balance := range nonEmpty {
+		balances[walletId] = balance.WithdrawableAmount
+	}
+	return balances, nil
+}
+
+// coldWalletTotals returns the total balance of each wallet. Caps are measured
+// against it: funds on hold still occupy a wallet.
+func coldWalletTotals(portfolio *Portfolio, config *model.Config, wallets []model.Wallet) (map[string]decimal.Decimal, error) {
+	nonEmpty, err := collectWalletBalances(portfolio.Client, config, walletIdsOf(wallets), func(balance *Balance) bool {
+		return balance.Amount.IsPositive()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]decimal.Decimal)
+	for walletId, balance := range nonEmpty {
+		totals[walletId] = balance.Amount
+	}
+	return totals, nil
+}
+
+func walletIdsOf(wallets []model.Wallet) []string {
+	walletIds := make([]string, 0, len(wallets))
+	for _, wallet := range wallets {
+		walletIds = append(walletIds, wallet.WalletId)
+	}
+	return walletIds
+}
+
+func sumAllocations(allocations []Allocation) decimal.Decimal {
+	total := decimal.Zero
+	for _, allocation := range allocations {
+		total = total.Add(allocation.Amount)
+	}
+	return total
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// getMaxTransferAmount returns the largest amount of symbol a single transfer
+// may move. A limit on a rule wallet holding symbol takes precedence over the
+// limit on the rule itself.
+func getMaxTransferAmount(config *model.Config, rule model.Rule, symbol string) string {
+	for _, walletName := range rule.Wallets {
+		for _, wallet := range config.Wallets {
+			if wallet.Name == walletName && wallet.Asset == symbol && wallet.MaxTransferAmount != "" {
+				return wallet.MaxTransferAmount
+			}
+		}
+	}
+	return rule.MaxTransferAmount
+}
+
+// ChunkAmount splits amount into chunks of at most maxAmount, truncated to the
+// withdrawal granularity. The last chunk holds the remainder.
+func ChunkAmount(amount decimal.Decimal, maxAmount string) ([]decimal.Decimal, error) {
+	if maxAmount == "" {
+		return []decimal.Decimal{amount}, nil
+	}
+
+	chunkSize, err := decimal.NewFromString(maxAmount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max transfer amount '%s': %w", maxAmount, err)
+	}
+	chunkSize = chunkSize.Truncate(maxWithdrawalGranularity)
+	if chunkSize.LessThan(minTransactionAmount) {
+		return nil, fmt.Errorf("max transfer amount '%s' is below the minimum transaction amount", maxAmount)
+	}
+
+	var chunks []decimal.Decimal
+	for remaining := amount; remaining.IsPositive(); remaining = remaining.Sub(chunkSize) {
+		chunks = append(chunks, decimal.Min(remaining, chunkSize))
+	}
+	return chunks, nil
+}
+
+// advanceSequence is called once the transfer with the given idempotency key
+// reached a terminal status. If it completed, the next chunk of its sequence
+// is submitted; otherwise the rest of the sequence is cancelled.
+func advanceSequence(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, idempotencyKey, status string) {
+	dependents, err := ledger.Dependents(idempotencyKey)
+	if err != nil {
+		zap.L().Error("could not read queued chunks",
+			zap.String("idempotency_key", idempotencyKey),
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, dependent := range dependents {
+		if dependent.Status != store.StatusQueued {
+			continue
+		}
+
+		if status == "TRANSACTION_DONE" {
+			zap.L().Info("previous chunk completed, submitting next chunk",
+				zap.String("idempotency_key", dependent.IdempotencyKey),
+				zap.String("rule", dependent.RuleName),
+				zap.String("operation_id", dependent.OperationId),
+			)
+			recordStatus(ledger, dependent, store.StatusPending)
+			dependent.SetStatus(store.StatusPending)
+			_ = submitTransfer(client, ledger, config, dependent)
+			continue
+		}
+
+		zap.L().Warn("previous chunk did not complete, cancelling the rest of the sequence",
+			zap.String("idempotency_key", dependent.IdempotencyKey),
+			zap.String("previous_status", status),
+			zap.String("rule", dependent.RuleName),
+			zap.String("operation_id", dependent.OperationId),
+		)
+		recordStatus(ledger, dependent, store.StatusCancelled)
+		advanceSequence(client, ledger, config, dependent.IdempotencyKey, store.StatusCancelled)
+	}
+}
+
+// resumeSequence advances a queued chunk whose predecessor reached a terminal
+// status while the sweeper was not running.
+func resumeSequence(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, record store.TransferRecord) {
+	previous, err := ledger.Get(record.DependsOn)
+	if err != nil {
+		zap.L().Error("could not read previous chunk",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("depends_on", record.DependsOn),
+			zap.Error(err),
+		)
+		return
+	}
+	if previous.IsTerminal() {
+		advanceSequence(client, ledger, config, previous.IdempotencyKey, previous.Status)
+	}
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// idempotencyNamespace scopes derived idempotency keys to the sweeper so they
+// cannot collide with keys generated by other Prime integrations.
+var idempotencyNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("github.com/coinbase-samples/prime-sweeper-go"))
+
+// IdempotencyKey derives the idempotency key for a transfer from the rule, the
+// time the rule was scheduled to fire, the source wallet and the symbol. The
+// same inputs always yield the same key, so re-executing a tick after a retry
+// or a crash is deduplicated by Prime.
+func IdempotencyKey(ruleName string, scheduledAt time.Time, sourceWalletId, symbol string) string {
+	name := fmt.Sprintf("%s|%s|%s|%s",
+		ruleName,
+		scheduledAt.UTC().Format(time.RFC3339Nano),
+		sourceWalletId,
+		symbol,
+	)
+	return uuid.NewSHA1(idempotencyNamespace, []byte(name)).String()
+}
+
+// PartIdempotencyKey derives the key of one request of a transfer that is
+// split across destinations and chunks. part numbers the destination and chunk
+// the request within it; both go into a single hash so that no two requests
+// of a transfer can share a key. The first request keeps the original key, so
+// unsplit transfers are unaffected.
+func PartIdempotencyKey(key string, part, chunk int) string {
+	if part == 0 && chunk == 0 {
+		return key
+	}
+	return uuid.NewSHA1(idempotencyNamespace, []byte(fmt.Sprintf("%s|%d|%d", key, part, chunk))).String()
+}
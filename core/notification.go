@@ -0,0 +1,117 @@
+package core
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"time"
+)
+
+const defaultApprovalReminderFrequency model.Seconds = 1800
+
+// approvalNotifier sends the approval notifications for one tracked transfer:
+// one when it is first seen awaiting approval and reminders for as long as it
+// keeps waiting. Prime returns an approval URL for every transfer, so only the
+// polled status tells whether approval is actually needed.
+type approvalNotifier struct {
+	record       store.TransferRecord
+	frequency    time.Duration
+	observed     bool
+	pendingSince time.Time
+	lastSent     time.Time
+}
+
+func newApprovalNotifier(config *model.Config, record store.TransferRecord) *approvalNotifier {
+	frequency := config.Notifications.ApprovalReminderFrequency
+	if frequency <= 0 {
+		frequency = defaultApprovalReminderFrequency
+	}
+	return &approvalNotifier{record: record, frequency: frequency.Duration()}
+}
+
+// submittedAt returns when record was submitted to Prime, or its last update
+// if that was not recorded.
+func submittedAt(record store.TransferRecord) time.Time {
+	for _, transition := range record.Transitions {
+		if transition.Status == store.StatusSubmitted {
+			return transition.At
+		}
+	}
+	return record.UpdatedAt
+}
+
+func (n *approvalNotifier) observe(status, transactionId string, now time.Time) {
+	first := !n.observed
+	n.observed = true
+	if !utils.IsAwaitingApproval(status) {
+		n.pendingSince = time.Time{}
+		return
+	}
+
+	eventType := notify.EventApprovalReminder
+	if n.pendingSince.IsZero() {
+		n.pendingSince = now
+		// A transfer awaiting approval on its first poll has been waiting
+		// since it was submitted.
+		if submitted := submittedAt(n.record); first && n.record.ApprovalUrl != "" && !submitted.IsZero() {
+			n.pendingSince = submitted
+		}
+		eventType = notify.EventApprovalPending
+	} else if now.Sub(n.lastSent) < n.frequency {
+		return
+	}
+	n.lastSent = now
+
+	event := transferEvent(eventType, notify.SeverityWarning, n.record)
+	event.TransactionId = transactionId
+	event.Status = status
+	pendingSince := n.pendingSince
+	event.PendingSince = &pendingSince
+	event.Time = now
+	notify.Send(event)
+}
+
+func transferEvent(eventType, severity string, record store.TransferRecord) notify.Event {
+	return notify.Event{
+		Type:                eventType,
+		Severity:            severity,
+		RuleName:            record.RuleName,
+		OperationId:         record.OperationId,
+		IdempotencyKey:      record.IdempotencyKey,
+		TransactionId:       record.TransactionId,
+		Status:              record.Status,
+		Symbol:              record.Request.Symbol,
+		Amount:              record.Request.Amount,
+		SourceWalletId:      record.Request.SourceWalletId,
+		DestinationWalletId: record.Request.DestinationWalletId,
+		ApprovalUrl:         record.ApprovalUrl,
+	}
+}
+
+// notifyTerminalStatus reports a transfer that reached a terminal Prime
+// status.
+func notifyTerminalStatus(record store.TransferRecord, status string) {
+	eventType, severity := notify.EventTransferFailed, notify.SeverityError
+	switch status {
+	case "TRANSACTION_DONE":
+		eventType, severity = notify.EventTransferCompleted, notify.SeverityInfo
+	case "TRANSACTION_REJECTED":
+		eventType, severity = notify.EventTransferRejected, notify.SeverityWarning
+	}
+
+	event := transferEvent(eventType, severity, record)
+	event.Status = status
+	notify.Send(event)
+}
+
+func notifyRuleError(rule model.Rule, operationId, message string, err error) {
+	notify.Send(notify.Event{
+		Type:        notify.EventRuleError,
+		Severity:    notify.SeverityError,
+		RuleName:    rule.Name,
+		OperationId: operationId,
+		Message:     message,
+		Error:       err.Error(),
+	})
+}
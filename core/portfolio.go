@@ -0,0 +1,24 @@
+package core
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+)
+
+// Portfolio bundles the Prime client of a portfolio with the trading wallets
+// discovered in it. Rules only ever move funds within a single portfolio.
+type Portfolio struct {
+	Name           string
+	Client         utils.PrimeClient
+	TradingWallets map[string]WalletResponse
+}
+
+// NewPortfolio collects the trading wallets for every asset configured in
+// config, which is expected to be scoped to the portfolio.
+func NewPortfolio(name string, client utils.PrimeClient, config *model.Config) (*Portfolio, error) {
+	tradingWallets, err := CollectTradingWallets(client, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Portfolio{Name: name, Client: client, TradingWallets: tradingWallets}, nil
+}
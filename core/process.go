@@ -1,47 +1,142 @@
 package core
 
 import (
+	"errors"
+	"github.com/coinbase-samples/prime-sweeper-go/metrics"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
 	"go.uber.org/zap"
+	"time"
 )
 
+// ProcessTransfers runs rule once and returns the transfers it planned. The
+// error reports a run that did not complete, e.g. because balances could not
+// be read; it has been logged and notified already.
 func ProcessTransfers(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
 	config *model.Config,
 	rule model.Rule,
-	transferDetails model.TransferDetails) {
+	transferDetails model.TransferDetails) ([]PlannedTransfer, error) {
+
+	// A dry run only plans, so it is neither counted as an execution nor
+	// announced.
+	if !transferDetails.DryRun {
+		metrics.RuleExecutions.WithLabelValues(rule.Name).Inc()
+		defer func(start time.Time) {
+			metrics.RuleExecutionDuration.WithLabelValues(rule.Name).Observe(time.Since(start).Seconds())
+		}(time.Now())
+
+		notify.Send(notify.Event{
+			Type:        notify.EventRuleStarted,
+			Severity:    notify.SeverityInfo,
+			RuleName:    rule.Name,
+			OperationId: transferDetails.OperationId,
+		})
+		defer notify.Send(notify.Event{
+			Type:        notify.EventRuleFinished,
+			Severity:    notify.SeverityInfo,
+			RuleName:    rule.Name,
+			OperationId: transferDetails.OperationId,
+		})
+	}
 
 	zap.L().Info("checking for withdrawable balances",
 		zap.Any("rule", rule),
+		zap.String("portfolio", portfolio.Name),
 		zap.String("operation_id", transferDetails.OperationId),
+		zap.Bool("dry_run", transferDetails.DryRun),
 	)
 
+	if !transferDetails.DryRun {
+		if err := ResubmitPendingTransfers(portfolio.Client, ledger, config, rule); err != nil {
+			zap.L().Error("failed to resubmit pending transfers", zap.Error(err),
+				zap.String("rule", rule.Name),
+				zap.String("operation_id", transferDetails.OperationId),
+			)
+		}
+	}
+
+	if transferDetails.Direction == model.ColdToCold && len(rule.DestinationWallets) == 0 {
+		plan, err := InitiateRebalance(portfolio, ledger, config, rule, transferDetails)
+		if err != nil {
+			zap.L().Error("failed to rebalance wallets",
+				zap.Any("rule", rule),
+				zap.String("operation_id", transferDetails.OperationId),
+				zap.Error(err),
+			)
+			notifyRuleError(rule, transferDetails.OperationId, "failed to rebalance wallets", err)
+		}
+		return plan, err
+	}
+
 	var walletIds []string
 	if transferDetails.Direction == model.HotToCold {
 		assets := GetAssetsForRule(rule, config)
-		filteredWallets := FilterWalletsByAssets(assets, TradingWallets)
+		if len(transferDetails.Assets) > 0 {
+			assets = transferDetails.Assets
+		}
+		filteredWallets := FilterWalletsByAssets(assets, portfolio.TradingWallets)
 
 		for _, wallet := range filteredWallets {
 			walletIds = append(walletIds, wallet.Id)
 		}
-	} else if transferDetails.Direction == model.ColdToHot {
+	} else if transferDetails.Direction == model.ColdToHot || transferDetails.Direction == model.ColdToCold {
 		filteredWalletIds := FilterWalletsByName(transferDetails.WalletNames, config)
 		walletIds = filteredWalletIds
 	}
 
-	nonEmptyWallets, err := CollectWalletBalances(config, walletIds)
-	if err != nil {
-		zap.L().Error("failed to query wallet balances", zap.Error(err),
+	nonEmptyWallets, balancesErr := CollectWalletBalances(portfolio.Client, config, walletIds)
+	var failed WalletErrors
+	if errors.As(balancesErr, &failed) && len(failed) < len(walletIds) {
+		// Wallets that answered are still swept; the others are picked up
+		// again on the next run.
+		for walletId, walletErr := range failed {
+			zap.L().Error("failed to query wallet balance, skipping wallet", zap.Error(walletErr),
+				zap.String("rule", rule.Name),
+				zap.String("wallet_id", walletId),
+				zap.String("operation_id", transferDetails.OperationId),
+			)
+		}
+		notifyRuleError(rule, transferDetails.OperationId, "failed to query some wallet balances, skipping them", balancesErr)
+	} else if balancesErr != nil {
+		zap.L().Error("failed to query wallet balances", zap.Error(balancesErr),
 			zap.Any("rule", rule),
 			zap.String("operation_id", transferDetails.OperationId),
 		)
-		return
+		notifyRuleError(rule, transferDetails.OperationId, "failed to query wallet balances", balancesErr)
+		return nil, balancesErr
 	}
 
-	if err = InitiateTransfers(nonEmptyWallets, config, transferDetails.Direction, rule, transferDetails.OperationId); err != nil {
+	if transferDetails.Direction == model.ColdToHot && len(rule.TargetBalances) > 0 {
+		shortfalls, err := CollectShortfalls(portfolio, ledger, config, rule)
+		if err != nil {
+			zap.L().Error("failed to compute trading shortfalls", zap.Error(err),
+				zap.Any("rule", rule),
+				zap.String("operation_id", transferDetails.OperationId),
+			)
+			notifyRuleError(rule, transferDetails.OperationId, "failed to compute trading shortfalls", err)
+			return nil, err
+		}
+		zap.L().Info("trading shortfalls against target balances",
+			zap.Any("shortfalls", shortfalls),
+			zap.String("rule", rule.Name),
+			zap.String("operation_id", transferDetails.OperationId),
+		)
+		nonEmptyWallets = capToShortfalls(nonEmptyWallets, shortfalls)
+	}
+
+	plan, err := InitiateTransfers(portfolio, ledger, nonEmptyWallets, config, rule, transferDetails)
+	if err != nil {
 		zap.L().Error("failed to initiate transfers",
 			zap.Any("rule", rule),
 			zap.String("operation_id", transferDetails.OperationId),
 			zap.Error(err),
 		)
+		notifyRuleError(rule, transferDetails.OperationId, "failed to initiate transfers", err)
 	}
+
+	// Wallets skipped for a failed balance query leave the run incomplete.
+	return plan, errors.Join(balancesErr, err)
 }
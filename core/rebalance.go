@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// PlanRebalance returns, by source wallet ID, the transfers that bring every
+// wallet to its weight's share of the combined balance. Weights default to 1.
+// Wallets above their share are drained into wallets below it, both in the
+// order they are listed; each allocation's part is the index of its
+// destination, so it stays the same across re-executions of a tick.
+func PlanRebalance(wallets []model.Wallet, balances map[string]decimal.Decimal) (map[string][]Allocation, error) {
+	weights, total, err := walletWeights(wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	combined := decimal.Zero
+	for _, wallet := range wallets {
+		combined = combined.Add(balances[wallet.WalletId])
+	}
+
+	type imbalance struct {
+		index  int
+		amount decimal.Decimal
+	}
+	var surpluses, deficits []*imbalance
+	for i, wallet := range wallets {
+		target := combined.Mul(weights[i]).Div(total).Truncate(maxWithdrawalGranularity)
+		difference := balances[wallet.WalletId].Sub(target)
+		if difference.IsPositive() {
+			surpluses = append(surpluses, &imbalance{index: i, amount: difference})
+		} else if difference.IsNegative() {
+			deficits = append(deficits, &imbalance{index: i, amount: difference.Neg()})
+		}
+	}
+
+	moves := make(map[string][]Allocation)
+	for _, surplus := range surpluses {
+		for _, deficit := range deficits {
+			amount := decimal.Min(surplus.amount, deficit.amount).Truncate(maxWithdrawalGranularity)
+			if amount.LessThan(minTransactionAmount) {
+				continue
+			}
+			surplus.amount = surplus.amount.Sub(amount)
+			deficit.amount = deficit.amount.Sub(amount)
+
+			source := wallets[surplus.index].WalletId
+			moves[source] = append(moves[source], Allocation{
+				WalletId: wallets[deficit.index].WalletId,
+				Amount:   amount,
+				Part:     deficit.index,
+			})
+		}
+	}
+	return moves, nil
+}
+
+// InitiateRebalance moves funds between the wallets of a cold-to-cold rule
+// without destination wallets so that, per asset, each holds its weight's
+// share. An asset is left alone while transfers of an earlier rebalance are
+// still in flight, as the balances would not reflect them yet. Allocations
+// below the rule's min_sweep_amount are skipped.
+func InitiateRebalance(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
+	config *model.Config,
+	rule model.Rule,
+	transferDetails model.TransferDetails,
+) ([]PlannedTransfer, error) {
+
+	operationId := transferDetails.OperationId
+	if transferDetails.ScheduledAt.IsZero() {
+		return nil, fmt.Errorf("scheduled time not set for operation %s", operationId)
+	}
+
+	minSweepAmount := decimal.Zero
+	if rule.MinSweepAmount != "" {
+		var err error
+		minSweepAmount, err = decimal.NewFromString(rule.MinSweepAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min sweep amount '%s': %w", rule.MinSweepAmount, err)
+		}
+	}
+
+	records, err := ledger.NonTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ledger: %w", err)
+	}
+	inFlight := make(map[string]bool)
+	for _, record := range records {
+		if record.RuleName == rule.Name && record.Request.PortfolioId == portfolio.Client.PortfolioId() {
+			inFlight[record.Request.Symbol] = true
+		}
+	}
+
+	assets := make(map[string]bool)
+	for _, asset := range GetAssetsForRule(rule, config) {
+		assets[asset] = true
+	}
+	symbols := make([]string, 0, len(assets))
+	for asset := range assets {
+		symbols = append(symbols, asset)
+	}
+	sort.Strings(symbols)
+
+	var plan []PlannedTransfer
+	for _, symbol := range symbols {
+		if inFlight[symbol] {
+			zap.L().Info("previous rebalance still in flight, skipping asset",
+				zap.String("rule", rule.Name),
+				zap.String("symbol", symbol),
+				zap.String("operation_id", operationId),
+			)
+			continue
+		}
+
+		wallets := coldWalletsForAsset(config, rule.Wallets, symbol)
+		balances, err := coldWalletBalances(portfolio, config, wallets)
+		if err != nil {
+			// Without every balance the shares cannot be computed.
+			zap.L().Error("failed to query wallet balances, skipping asset", zap.Error(err),
+				zap.String("rule", rule.Name),
+				zap.String("symbol", symbol),
+				zap.String("operation_id", operationId),
+			)
+			notifyRuleError(rule, operationId, fmt.Sprintf("failed to query %s wallet balances, skipping rebalance", symbol), err)
+			continue
+		}
+
+		moves, err := PlanRebalance(wallets, balances)
+		if err != nil {
+			return plan, err
+		}
+		zap.L().Info("rebalancing wallets",
+			zap.String("rule", rule.Name),
+			zap.String("symbol", symbol),
+			zap.Any("balances", balances),
+			zap.Any("moves", moves),
+			zap.String("operation_id", operationId),
+		)
+
+		for _, wallet := range wallets {
+			var allocations []Allocation
+			for _, allocation := range moves[wallet.WalletId] {
+				if allocation.Amount.GreaterThanOrEqual(minSweepAmount) {
+					allocations = append(allocations, allocation)
+				}
+			}
+			if len(allocations) == 0 {
+				continue
+			}
+
+			sequences, err := buildSequences(portfolio, config, rule, transferDetails, wallet.WalletId, symbol, allocations)
+			if err != nil {
+				zap.L().Error("error preparing transfer request",
+					zap.Any("rule", rule),
+					zap.String("wallet_id", wallet.WalletId),
+					zap.String("operation_id", operationId),
+					zap.Error(err),
+				)
+				notifyRuleError(rule, operationId, fmt.Sprintf("error preparing transfer from wallet %s", wallet.WalletId), err)
+				continue
+			}
+			plan = append(plan, submitSequences(portfolio, ledger, config, rule, transferDetails, sequences)...)
+		}
+	}
+
+	return plan, nil
+}
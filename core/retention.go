@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/shopspring/decimal"
+)
+
+var oneHundred = decimal.NewFromInt(100)
+
+// getRetention returns the retention settings for symbol. Settings on a rule
+// wallet holding symbol take precedence over the settings on the rule itself.
+func getRetention(config *model.Config, rule model.Rule, symbol string) (string, string) {
+	for _, walletName := range rule.Wallets {
+		for _, wallet := range config.Wallets {
+			if wallet.Name != walletName || wallet.Asset != symbol {
+				continue
+			}
+			if wallet.RetainAmount != "" || wallet.RetainPercentage != "" {
+				return wallet.RetainAmount, wallet.RetainPercentage
+			}
+		}
+	}
+	return rule.RetainAmount, rule.RetainPercentage
+}
+
+// RetainedAmount returns how much of withdrawable must stay behind. When both
+// a fixed amount and a percentage are configured the larger floor applies.
+func RetainedAmount(retainAmount, retainPercentage string, withdrawable decimal.Decimal) (decimal.Decimal, error) {
+	retained := decimal.Zero
+
+	if retainAmount != "" {
+		amount, err := decimal.NewFromString(retainAmount)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid retain amount '%s': %w", retainAmount, err)
+		}
+		retained = decimal.Max(retained, amount)
+	}
+
+	if retainPercentage != "" {
+		percentage, err := decimal.NewFromString(retainPercentage)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid retain percentage '%s': %w", retainPercentage, err)
+		}
+		retained = decimal.Max(retained, withdrawable.Mul(percentage).Div(oneHundred))
+	}
+
+	return retained, nil
+}
+
+// sweepableAmount returns the part of balance that may leave the wallet once
+// the configured retention has been kept behind.
+func sweepableAmount(config *model.Config, rule model.Rule, balance *Balance) (decimal.Decimal, error) {
+	retainAmount, retainPercentage := getRetention(config, rule, balance.Symbol)
+
+	retained, err := RetainedAmount(retainAmount, retainPercentage, balance.WithdrawableAmount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return balance.WithdrawableAmount.Sub(retained), nil
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/shopspring/decimal"
+	"sort"
+)
+
+// Shortfall returns how much must be added to balance to reach target. It is
+// zero when the balance is already at or above the target.
+func Shortfall(target string, balance decimal.Decimal) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(target)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid target balance '%s': %w", target, err)
+	}
+	return decimal.Max(decimal.Zero, amount.Sub(balance)), nil
+}
+
+// CollectShortfalls returns, per asset, how much the trading wallet is below
+// the target balance of the rule. The balance includes funds on hold, which
+// are still in the wallet. Transfers into the trading wallet that have not
+// reached a terminal status count towards the balance, so a top-up that is
+// still awaiting approval is not requested a second time.
+func CollectShortfalls(portfolio *Portfolio, ledger *store.Ledger, config *model.Config, rule model.Rule) (map[string]decimal.Decimal, error) {
+	var walletIds []string
+	for asset := range rule.TargetBalances {
+		wallet, exists := portfolio.TradingWallets[asset]
+		if !exists {
+			return nil, fmt.Errorf("no trading wallet for asset '%s'", asset)
+		}
+		walletIds = append(walletIds, wallet.Id)
+	}
+
+	balances, err := CollectWalletBalances(portfolio.Client, config, walletIds)
+	if err != nil {
+		return nil, err
+	}
+
+	inFlight, err := inFlightAmounts(ledger, portfolio.Client.PortfolioId())
+	if err != nil {
+		return nil, err
+	}
+
+	shortfalls := make(map[string]decimal.Decimal)
+	for asset, target := range rule.TargetBalances {
+		walletId := portfolio.TradingWallets[asset].Id
+
+		current := inFlight[walletId]
+		if balance, exists := balances[walletId]; exists {
+			current = current.Add(balance.Amount)
+		}
+
+		shortfall, err := Shortfall(target, current)
+		if err != nil {
+			return nil, fmt.Errorf("asset '%s': %w", asset, err)
+		}
+		shortfalls[asset] = shortfall
+	}
+	return shortfalls, nil
+}
+
+// inFlightAmounts sums the amounts of non-terminal transfers by destination
+// wallet.
+func inFlightAmounts(ledger *store.Ledger, portfolioId string) (map[string]decimal.Decimal, error) {
+	records, err := ledger.NonTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ledger: %w", err)
+	}
+
+	amounts := make(map[string]decimal.Decimal)
+	for _, record := range records {
+		if record.Request.PortfolioId != portfolioId {
+			continue
+		}
+		amount, err := decimal.NewFromString(record.Request.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount in transfer %s: %w", record.IdempotencyKey, err)
+		}
+		walletId := record.Request.DestinationWalletId
+		amounts[walletId] = amounts[walletId].Add(amount)
+	}
+	return amounts, nil
+}
+
+// capToShortfalls limits the cold wallet balances so that together they cover
+// no more than the shortfall of their asset. Wallets are drawn from in wallet
+// ID order and wallets with nothing left to contribute are dropped.
+func capToShortfalls(balances map[string]*Balance, shortfalls map[string]decimal.Decimal) map[string]*Balance {
+	walletIds := make([]string, 0, len(balances))
+	for walletId := range balances {
+		walletIds = append(walletIds, walletId)
+	}
+	sort.Strings(walletIds)
+
+	remaining := make(map[string]decimal.Decimal)
+	for asset, shortfall := range shortfalls {
+		remaining[asset] = shortfall
+	}
+
+	capped := make(map[string]*Balance)
+	for _, walletId := range walletIds {
+		balance := balances[walletId]
+		amount := decimal.Min(balance.WithdrawableAmount, remaining[balance.Symbol])
+		if !amount.IsPositive() {
+			continue
+		}
+		remaining[balance.Symbol] = remaining[balance.Symbol].Sub(amount)
+		capped[walletId] = &Balance{
+			Id:                 balance.Id,
+			Symbol:             balance.Symbol,
+			WithdrawableAmount: amount,
+		}
+	}
+	return capped
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/shopspring/decimal"
+	"sort"
+)
+
+// CollectThresholdBalances returns the trading balance of every asset with a
+// threshold on the rule, keyed by asset. Assets without a trading wallet or
+// with an empty balance are omitted.
+func CollectThresholdBalances(portfolio *Portfolio, config *model.Config, rule model.Rule) (map[string]decimal.Decimal, error) {
+	var walletIds []string
+	for asset := range rule.Thresholds {
+		if wallet, exists := portfolio.TradingWallets[asset]; exists {
+			walletIds = append(walletIds, wallet.Id)
+		}
+	}
+
+	balances, err := CollectWalletBalances(portfolio.Client, config, walletIds)
+	if err != nil {
+		return nil, err
+	}
+
+	balancesByAsset := make(map[string]decimal.Decimal)
+	for _, balance := range balances {
+		balancesByAsset[balance.Symbol] = balance.WithdrawableAmount
+	}
+	return balancesByAsset, nil
+}
+
+// AssetsAboveThreshold returns, in sorted order, the assets whose balance is at
+// or above the threshold configured on the rule.
+func AssetsAboveThreshold(rule model.Rule, balances map[string]decimal.Decimal) ([]string, error) {
+	var assets []string
+	for asset, value := range rule.Thresholds {
+		threshold, err := decimal.NewFromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold '%s' for asset '%s': %w", value, asset, err)
+		}
+
+		if balance, exists := balances[asset]; exists && balance.GreaterThanOrEqual(threshold) {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+	return assets, nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker owns the goroutines that follow submitted transfers until they
+// reach a terminal status. Stopping it cancels their shared root context;
+// transfers that are still in flight are picked up again from the ledger on
+// the next start.
+type Tracker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	stopped bool
+	active  map[string]bool
+	wg      sync.WaitGroup
+}
+
+func NewTracker() *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{ctx: ctx, cancel: cancel, active: make(map[string]bool)}
+}
+
+var (
+	trackerMu      sync.RWMutex
+	currentTracker = NewTracker()
+)
+
+// SetTracker replaces the tracker used for transfers submitted from now on.
+func SetTracker(tracker *Tracker) {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+
+	currentTracker = tracker
+}
+
+func getTracker() *Tracker {
+	trackerMu.RLock()
+	defer trackerMu.RUnlock()
+
+	return currentTracker
+}
+
+// Go runs fn in a tracked goroutine with the tracker's root context, unless a
+// goroutine for the same key is still running. It returns false without
+// running fn once the tracker is stopped.
+func (t *Tracker) Go(key string, fn func(ctx context.Context)) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return false
+	}
+	if t.active[key] {
+		return true
+	}
+	t.active[key] = true
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer func() {
+			t.mu.Lock()
+			delete(t.active, key)
+			t.mu.Unlock()
+		}()
+		fn(t.ctx)
+	}()
+	return true
+}
+
+// Stop cancels every tracked goroutine and waits for them to return or for
+// ctx to be done.
+func (t *Tracker) Stop(ctx context.Context) error {
+	t.mu.Lock()
+	t.stopped = true
+	t.mu.Unlock()
+	t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,17 +2,46 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/metrics"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
 	"github.com/coinbase-samples/prime-sweeper-go/utils"
-	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"time"
 )
 
 const maxWithdrawalGranularity int32 = 8
 
+const (
+	// stalePollFrequency is the slowest a transfer is polled once its fast
+	// polling window has passed.
+	stalePollFrequency = 5 * time.Minute
+	// maxTrackingAge is how long after submission a transfer is followed
+	// before it is recorded as untracked.
+	maxTrackingAge = 7 * 24 * time.Hour
+)
+
+var (
+	errNothingToSweep      = errors.New("no sweepable amount left after retention")
+	errBelowMinSweepAmount = errors.New("sweepable amount below rule minimum")
+)
+
+// PlannedTransfer describes a transfer that a dry run would have submitted.
+type PlannedTransfer struct {
+	RuleName            string `json:"rule_name"`
+	OperationId         string `json:"operation_id"`
+	SourceWalletId      string `json:"source_wallet_id"`
+	DestinationWalletId string `json:"destination_wallet_id"`
+	Symbol              string `json:"symbol"`
+	Amount              string `json:"amount"`
+	IdempotencyKey      string `json:"idempotency_key"`
+}
+
 func findColdWalletIdForAsset(config *model.Config, asset string, walletType string) (string, error) {
 	for _, wallet := range config.Wallets {
 		if wallet.Asset == asset && wallet.Type == walletType {
@@ -29,73 +58,302 @@ func findHotWalletIdForAsset(tradingWalletsMap map[string]WalletResponse, asset
 	return "", fmt.Errorf("hot wallet for asset '%s' not found", asset)
 }
 
-func findWalletIdForAsset(config *model.Config, symbol string, direction model.TransferDirection) (string, error) {
+// prepareTransferRequests builds the requests that move balance out of
+// sourceWalletId. Hot-to-cold sweeps and cold-to-cold drains are split across
+// the destination cold wallets according to the rule's allocation strategy,
+// and every share larger than the maximum transfer amount is split into
+// chunks. Each returned sequence holds the chunks of one share, which must be
+// submitted one after another.
+func prepareTransferRequests(portfolio *Portfolio,
+	ledger *store.Ledger,
+	sourceWalletId string,
+	balance *Balance,
+	config *model.Config,
+	rule model.Rule,
+	transferDetails model.TransferDetails,
+) ([][]*prime.CreateWalletTransferRequest, error) {
+
+	direction := transferDetails.Direction
+
+	amount := balance.WithdrawableAmount
+	if direction == model.HotToCold || direction == model.ColdToCold {
+		var err error
+		amount, err = sweepableAmount(config, rule, balance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cappedAmount := amount.Truncate(maxWithdrawalGranularity)
+	if cappedAmount.LessThan(minTransactionAmount) {
+		return nil, errNothingToSweep
+	}
+
+	if rule.MinSweepAmount != "" {
+		minSweepAmount, err := decimal.NewFromString(rule.MinSweepAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min sweep amount '%s': %w", rule.MinSweepAmount, err)
+		}
+		if cappedAmount.LessThan(minSweepAmount) {
+			return nil, errBelowMinSweepAmount
+		}
+	}
+
+	var allocations []Allocation
 	switch direction {
-	case model.HotToCold:
-		return findColdWalletIdForAsset(config, symbol, "cold_custody")
+	case model.HotToCold, model.ColdToCold:
+		var err error
+		allocations, err = allocateSweep(portfolio, ledger, config, rule, balance.Symbol, cappedAmount)
+		if err != nil {
+			return nil, err
+		}
 	case model.ColdToHot:
-		return findHotWalletIdForAsset(TradingWallets, symbol)
+		walletId, err := findHotWalletIdForAsset(portfolio.TradingWallets, balance.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		allocations = []Allocation{{WalletId: walletId, Amount: cappedAmount}}
 	default:
-		return "", fmt.Errorf("invalid transfer direction")
+		return nil, fmt.Errorf("invalid transfer direction")
 	}
+
+	return buildSequences(portfolio, config, rule, transferDetails, sourceWalletId, balance.Symbol, allocations)
 }
 
-func prepareTransferRequest(client *prime.Client,
-	sourceWalletId string,
-	balance *Balance,
+// buildSequences turns the allocations of a transfer out of sourceWalletId
+// into requests, splitting every allocation larger than the maximum transfer
+// amount into chunks.
+func buildSequences(portfolio *Portfolio,
 	config *model.Config,
-	direction model.TransferDirection,
-) (*prime.CreateWalletTransferRequest, error) {
+	rule model.Rule,
+	transferDetails model.TransferDetails,
+	sourceWalletId string,
+	symbol string,
+	allocations []Allocation,
+) ([][]*prime.CreateWalletTransferRequest, error) {
 
-	destinationWalletId, err := findWalletIdForAsset(config, balance.Symbol, direction)
-	if err != nil {
-		return nil, err
-	}
+	key := IdempotencyKey(rule.Name, transferDetails.ScheduledAt, sourceWalletId, symbol)
+	maxAmount := getMaxTransferAmount(config, rule, symbol)
 
-	cappedAmount := balance.WithdrawableAmount.Truncate(maxWithdrawalGranularity)
+	sequences := make([][]*prime.CreateWalletTransferRequest, 0, len(allocations))
+	for _, allocation := range allocations {
+		chunks, err := ChunkAmount(allocation.Amount, maxAmount)
+		if err != nil {
+			return nil, err
+		}
 
-	request := prime.CreateWalletTransferRequest{
-		PortfolioId:         client.Credentials.PortfolioId,
-		SourceWalletId:      sourceWalletId,
-		Symbol:              balance.Symbol,
-		DestinationWalletId: destinationWalletId,
-		IdempotencyKey:      uuid.New().String(),
-		Amount:              cappedAmount.String(),
+		sequence := make([]*prime.CreateWalletTransferRequest, 0, len(chunks))
+		for i, chunk := range chunks {
+			sequence = append(sequence, &prime.CreateWalletTransferRequest{
+				PortfolioId:         portfolio.Client.PortfolioId(),
+				SourceWalletId:      sourceWalletId,
+				Symbol:              symbol,
+				DestinationWalletId: allocation.WalletId,
+				IdempotencyKey:      PartIdempotencyKey(key, allocation.Part, i),
+				Amount:              chunk.String(),
+			})
+		}
+		sequences = append(sequences, sequence)
 	}
 
-	return &request, nil
+	return sequences, nil
 }
 
-func logAndTrackTransfer(response *prime.CreateWalletTransferResponse,
+func logAndTrackTransfer(client utils.PrimeClient,
+	ledger *store.Ledger,
+	response *prime.CreateWalletTransferResponse,
 	config *model.Config,
-	sourceWalletId string,
-	destinationWalletId string,
-	operationId string,
+	record store.TransferRecord,
 ) {
 	zap.L().Info("initiated transfer",
 		zap.Any("response", response),
-		zap.String("source_wallet_id", sourceWalletId),
-		zap.String("destination_wallet_id", destinationWalletId),
-		zap.String("operation_id", operationId),
+		zap.String("source_wallet_id", record.Request.SourceWalletId),
+		zap.String("destination_wallet_id", record.Request.DestinationWalletId),
+		zap.String("operation_id", record.OperationId),
 	)
 
-	go trackTransaction(response.ActivityId, config, response.ApprovalUrl, operationId)
+	startTracking(client, ledger, config, record)
+}
+
+// startTracking follows record in a goroutine of the current tracker. Once the
+// tracker is stopped, on shutdown or in one-off commands, the record is left as
+// is, to be tracked again on the next daemon start.
+func startTracking(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, record store.TransferRecord) {
+	started := getTracker().Go(record.IdempotencyKey, func(ctx context.Context) {
+		trackTransaction(ctx, client, ledger, config, record)
+	})
+	if !started {
+		zap.L().Info("transfer tracking stopped, transfer will be tracked on the next daemon start",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("operation_id", record.OperationId),
+		)
+	}
 }
 
+// submitTransfer sends a recorded transfer to Prime and starts tracking it.
+// Resubmitting a record is safe because Prime deduplicates on the idempotency
+// key.
+func submitTransfer(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, record store.TransferRecord) error {
+	ctx, cancel := utils.GetContextWithTimeout(config)
+	response, err := client.CreateWalletTransfer(ctx, &record.Request)
+	cancel()
+	if err != nil && utils.IsRetryable(err) {
+		// Prime may or may not have seen the request. The record stays
+		// PENDING so that it is resubmitted with the same idempotency key
+		// at the start of the rule's next run, or on restart, instead of
+		// being given up on.
+		zap.L().Error("could not create transfer, leaving it pending for resubmission",
+			zap.String("rule", record.RuleName),
+			zap.String("wallet_id", record.Request.SourceWalletId),
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+		event := transferEvent(notify.EventTransferFailed, notify.SeverityWarning, record)
+		event.Status = store.StatusPending
+		event.Message = "transient error, the transfer will be resubmitted"
+		event.Error = err.Error()
+		notify.Send(event)
+		return err
+	}
+	if err != nil {
+		zap.L().Error("could not create transfer",
+			zap.String("rule", record.RuleName),
+			zap.String("wallet_id", record.Request.SourceWalletId),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+		recordStatus(ledger, record, store.StatusSubmissionFailed)
+		metrics.TransfersFailed.WithLabelValues(record.RuleName, record.Request.Symbol).Inc()
+		event := transferEvent(notify.EventTransferFailed, notify.SeverityError, record)
+		event.Status = store.StatusSubmissionFailed
+		event.Error = err.Error()
+		notify.Send(event)
+		advanceSequence(client, ledger, config, record.IdempotencyKey, store.StatusSubmissionFailed)
+		return err
+	}
+
+	metrics.TransfersInitiated.WithLabelValues(record.RuleName, record.Request.Symbol).Inc()
+	if amount, err := decimal.NewFromString(record.Request.Amount); err == nil {
+		metrics.SweptAmount.WithLabelValues(record.RuleName, record.Request.Symbol).Add(amount.InexactFloat64())
+	}
+
+	record.ActivityId = response.ActivityId
+	record.TransactionId = response.TransactionId
+	record.ApprovalUrl = response.ApprovalUrl
+	record.SetStatus(store.StatusSubmitted)
+
+	if err := ledger.Update(record.IdempotencyKey, func(stored *store.TransferRecord) {
+		stored.ActivityId = record.ActivityId
+		stored.TransactionId = record.TransactionId
+		stored.ApprovalUrl = record.ApprovalUrl
+		stored.SetStatus(store.StatusSubmitted)
+	}); err != nil {
+		zap.L().Error("could not record submitted transfer",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+	}
+
+	notify.Send(transferEvent(notify.EventTransferInitiated, notify.SeverityInfo, record))
+	logAndTrackTransfer(client, ledger, response, config, record)
+	return nil
+}
+
+func recordStatus(ledger *store.Ledger, record store.TransferRecord, status string) {
+	if err := ledger.UpdateStatus(record.IdempotencyKey, status); err != nil {
+		zap.L().Error("could not record transfer status",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("status", status),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+	}
+}
+
+// ResubmitPendingTransfers resubmits the transfers of rule that were recorded
+// but never confirmed as submitted, e.g. because Prime kept failing with
+// transient errors. Runs derive new idempotency keys from their own scheduled
+// time, so without this such transfers would only be retried on restart while
+// still counting against the velocity limits.
+func ResubmitPendingTransfers(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, rule model.Rule) error {
+	records, err := ledger.NonTerminal()
+	if err != nil {
+		return fmt.Errorf("cannot read ledger: %w", err)
+	}
+
+	for _, record := range records {
+		if record.RuleName != rule.Name || record.Status != store.StatusPending ||
+			record.Request.PortfolioId != client.PortfolioId() {
+			continue
+		}
+
+		zap.L().Info("resubmitting pending transfer",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("rule", record.RuleName),
+			zap.String("operation_id", record.OperationId),
+		)
+		_ = submitTransfer(client, ledger, config, record)
+	}
+	return nil
+}
+
+// ResumeTransfers picks up every transfer of the client's portfolio that has
+// not reached a terminal status: transfers that were never confirmed as
+// submitted are resubmitted, queued chunks whose predecessor finished are
+// advanced and submitted transfers are tracked again.
+func ResumeTransfers(client utils.PrimeClient, ledger *store.Ledger, config *model.Config) error {
+	records, err := ledger.NonTerminal()
+	if err != nil {
+		return fmt.Errorf("cannot read ledger: %w", err)
+	}
+
+	for _, record := range records {
+		if record.Request.PortfolioId != client.PortfolioId() {
+			continue
+		}
+
+		zap.L().Info("resuming transfer",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("status", record.Status),
+			zap.String("rule", record.RuleName),
+			zap.String("operation_id", record.OperationId),
+		)
+
+		switch record.Status {
+		case store.StatusPending:
+			_ = submitTransfer(client, ledger, config, record)
+			continue
+		case store.StatusQueued:
+			resumeSequence(client, ledger, config, record)
+			continue
+		}
+
+		startTracking(client, ledger, config, record)
+	}
+
+	return nil
+}
+
+// InitiateTransfers submits a transfer for every balance in walletsMap. When
+// transferDetails.DryRun is set nothing is submitted; the transfers that would
+// have been created are returned instead.
 func InitiateTransfers(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
 	walletsMap map[string]*Balance,
 	config *model.Config,
-	direction model.TransferDirection,
 	rule model.Rule,
-	operationId string,
-) error {
+	transferDetails model.TransferDetails,
+) ([]PlannedTransfer, error) {
 
-	client, err := utils.GetClientFromEnv()
-	if err != nil {
-		zap.L().Error("cannot get client from environment", zap.Error(err))
-		return err
+	operationId := transferDetails.OperationId
+	if transferDetails.ScheduledAt.IsZero() {
+		return nil, fmt.Errorf("scheduled time not set for operation %s", operationId)
 	}
 
+	var plan []PlannedTransfer
 	for walletId, balance := range walletsMap {
 		zap.L().Info("found wallet balance",
 			zap.String("wallet_id", walletId),
@@ -104,38 +362,206 @@ func InitiateTransfers(
 			zap.String("operation_id", operationId),
 		)
 
-		ctx, cancel := utils.GetContextWithTimeout(config)
-		request, err := prepareTransferRequest(client, walletId, balance, config, direction)
-		if err != nil {
-			zap.L().Error("error preparing transfer request",
+		sequences, err := prepareTransferRequests(portfolio, ledger, walletId, balance, config, rule, transferDetails)
+		if errors.Is(err, errNothingToSweep) || errors.Is(err, errBelowMinSweepAmount) {
+			zap.L().Info("skipping transfer",
 				zap.Any("rule", rule),
 				zap.String("wallet_id", walletId),
 				zap.String("operation_id", operationId),
-				zap.Error(err),
+				zap.String("reason", err.Error()),
 			)
 			continue
 		}
-
-		response, err := client.CreateWalletTransfer(ctx, request)
-		cancel()
 		if err != nil {
-			zap.L().Error("could not create transfer",
+			zap.L().Error("error preparing transfer request",
 				zap.Any("rule", rule),
 				zap.String("wallet_id", walletId),
 				zap.String("operation_id", operationId),
 				zap.Error(err),
 			)
+			notifyRuleError(rule, operationId, fmt.Sprintf("error preparing transfer from wallet %s", walletId), err)
 			continue
 		}
 
-		logAndTrackTransfer(response, config, request.SourceWalletId, request.DestinationWalletId, operationId)
+		plan = append(plan, submitSequences(portfolio, ledger, config, rule, transferDetails, sequences)...)
 	}
 
-	return nil
+	return plan, nil
+}
+
+// submitSequences records and submits every sequence, or only returns the
+// transfers they would create when transferDetails.DryRun is set.
+func submitSequences(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
+	config *model.Config,
+	rule model.Rule,
+	transferDetails model.TransferDetails,
+	sequences [][]*prime.CreateWalletTransferRequest,
+) []PlannedTransfer {
+
+	var plan []PlannedTransfer
+	for _, sequence := range sequences {
+		if !transferDetails.DryRun {
+			recordAndSubmit(portfolio, ledger, config, rule, transferDetails.OperationId, sequence)
+			continue
+		}
+
+		for _, request := range sequence {
+			planned := PlannedTransfer{
+				RuleName:            rule.Name,
+				OperationId:         transferDetails.OperationId,
+				SourceWalletId:      request.SourceWalletId,
+				DestinationWalletId: request.DestinationWalletId,
+				Symbol:              request.Symbol,
+				Amount:              request.Amount,
+				IdempotencyKey:      request.IdempotencyKey,
+			}
+			zap.L().Info("planned transfer", zap.Any("plan", planned))
+			plan = append(plan, planned)
+		}
+	}
+	return plan
+}
+
+// recordAndSubmit submits the first request of sequence unless the ledger
+// shows it was already submitted for this schedule. Records are written before
+// submission so a crash in between leaves a pending record that is resubmitted
+// on startup. The remaining requests are queued behind their predecessor and
+// submitted as each one completes.
+func recordAndSubmit(
+	portfolio *Portfolio,
+	ledger *store.Ledger,
+	config *model.Config,
+	rule model.Rule,
+	operationId string,
+	sequence []*prime.CreateWalletTransferRequest,
+) {
+	request := sequence[0]
+	existing, err := ledger.Get(request.IdempotencyKey)
+	if err == nil {
+		if existing.Status == store.StatusPending {
+			_ = submitTransfer(portfolio.Client, ledger, config, *existing)
+			return
+		}
+		zap.L().Info("transfer already submitted for this schedule, skipping",
+			zap.Any("rule", rule),
+			zap.String("wallet_id", request.SourceWalletId),
+			zap.String("idempotency_key", request.IdempotencyKey),
+			zap.String("status", existing.Status),
+			zap.String("operation_id", operationId),
+		)
+		return
+	}
+	if !errors.Is(err, store.ErrTransferNotFound) {
+		zap.L().Error("could not read ledger, not submitting",
+			zap.Any("rule", rule),
+			zap.String("wallet_id", request.SourceWalletId),
+			zap.String("operation_id", operationId),
+			zap.Error(err),
+		)
+		notifyRuleError(rule, operationId, "could not read ledger, not submitting", err)
+		return
+	}
+
+	record, err := recordSequence(ledger, config, rule, operationId, sequence)
+	if errors.Is(err, errVelocityLimit) {
+		zap.L().Warn("transfer would exceed a velocity limit, skipping",
+			zap.Any("rule", rule),
+			zap.String("wallet_id", request.SourceWalletId),
+			zap.String("symbol", request.Symbol),
+			zap.String("operation_id", operationId),
+			zap.String("reason", err.Error()),
+		)
+		metrics.TransfersOverLimit.WithLabelValues(rule.Name, request.Symbol).Inc()
+		event := transferEvent(notify.EventTransferSkipped, notify.SeverityWarning, store.TransferRecord{
+			OperationId: operationId,
+			RuleName:    rule.Name,
+			Request:     *request,
+		})
+		event.Message = err.Error()
+		notify.Send(event)
+		return
+	}
+	if err != nil {
+		zap.L().Error("could not record transfer, not submitting",
+			zap.Any("rule", rule),
+			zap.String("wallet_id", request.SourceWalletId),
+			zap.String("operation_id", operationId),
+			zap.Error(err),
+		)
+		notifyRuleError(rule, operationId, "could not record transfer, not submitting", err)
+		return
+	}
+
+	if len(sequence) > 1 {
+		zap.L().Info("transfer split into sequential chunks",
+			zap.Any("rule", rule),
+			zap.String("wallet_id", request.SourceWalletId),
+			zap.Int("chunks", len(sequence)),
+			zap.String("operation_id", operationId),
+		)
+	}
+
+	_ = submitTransfer(portfolio.Client, ledger, config, record)
+}
+
+// recordSequence checks sequence against the velocity limits and records it:
+// the first request as pending and each further request queued behind its
+// predecessor. It returns the record of the first request.
+func recordSequence(
+	ledger *store.Ledger,
+	config *model.Config,
+	rule model.Rule,
+	operationId string,
+	sequence []*prime.CreateWalletTransferRequest,
+) (store.TransferRecord, error) {
+	velocityMu.Lock()
+	defer velocityMu.Unlock()
+
+	if len(config.Limits) > 0 {
+		history, err := ledger.List()
+		if err != nil {
+			return store.TransferRecord{}, fmt.Errorf("cannot read transfer history: %w", err)
+		}
+		if err := CheckVelocityLimits(config.Limits, history, sequence, time.Now().UTC()); err != nil {
+			return store.TransferRecord{}, err
+		}
+	}
+
+	record := store.TransferRecord{
+		IdempotencyKey: sequence[0].IdempotencyKey,
+		OperationId:    operationId,
+		RuleName:       rule.Name,
+		Request:        *sequence[0],
+		Status:         store.StatusPending,
+	}
+	if err := ledger.Create(&record); err != nil {
+		return store.TransferRecord{}, err
+	}
+
+	previous := record.IdempotencyKey
+	for _, chunk := range sequence[1:] {
+		queued := store.TransferRecord{
+			IdempotencyKey: chunk.IdempotencyKey,
+			OperationId:    operationId,
+			RuleName:       rule.Name,
+			Request:        *chunk,
+			Status:         store.StatusQueued,
+			DependsOn:      previous,
+		}
+		if err := ledger.Create(&queued); err != nil {
+			recordStatus(ledger, record, store.StatusSubmissionFailed)
+			return store.TransferRecord{}, fmt.Errorf("cannot queue transfer chunk: %w", err)
+		}
+		previous = queued.IdempotencyKey
+	}
+
+	return record, nil
 }
 
 func logTransactionStatus(
-	client *prime.Client,
+	client utils.PrimeClient,
 	ctx context.Context,
 	transactionId,
 	lastStatus,
@@ -143,7 +569,7 @@ func logTransactionStatus(
 ) (string, error) {
 
 	transactionResp, err := client.GetTransaction(ctx, &prime.GetTransactionRequest{
-		PortfolioId:   client.Credentials.PortfolioId,
+		PortfolioId:   client.PortfolioId(),
 		TransactionId: transactionId,
 	})
 	if err != nil {
@@ -167,52 +593,141 @@ func logTransactionStatus(
 	return currentStatus, nil
 }
 
-func trackTransaction(activityId string, config *model.Config, approvalUrl, operationId string) error {
-	client, err := utils.GetClientFromEnv()
-	if err != nil {
-		zap.L().Error("cannot get client from environment", zap.Error(err))
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), config.Daemon.TransferMonitorTimeoutDuration*time.Minute)
-	defer cancel()
+// trackTransaction polls the transaction of record until it reaches a terminal
+// status, then advances its sequence. Polling happens every
+// transfer_monitor_frequency seconds, starting right away, during the first
+// transfer_monitor_timeout_duration minutes and at least every
+// stalePollFrequency after that, so transfers that wait long for approval
+// still complete their sequence and stop counting as in flight. Failed polls
+// are retried on the next tick. A transfer still not terminal maxTrackingAge
+// after its submission is recorded as untracked, which also cancels the rest
+// of its sequence. Tracking stops early when root is cancelled for shutdown;
+// the transfer is then tracked again after restart.
+func trackTransaction(
+	root context.Context,
+	client utils.PrimeClient,
+	ledger *store.Ledger,
+	config *model.Config,
+	record store.TransferRecord,
+) {
+	start := time.Now()
+	window := time.NewTimer(config.Daemon.TransferMonitorTimeoutDuration.Duration())
+	defer window.Stop()
+	frequency := config.Daemon.TransferMonitorFrequency.Duration()
 
-	activityResp, err := client.GetActivity(ctx, &prime.GetActivityRequest{
-		PortfolioId: client.Credentials.PortfolioId,
-		Id:          activityId,
-	})
-	if err != nil {
-		zap.L().Error("could not get activity",
-			zap.String("activity_id", activityId),
-			zap.String("operation_id", operationId),
-			zap.Error(err),
-		)
-		return fmt.Errorf("could not get activity: %w", err)
+	operationId := record.OperationId
+	transactionId := record.TransactionId
+	lastStatus := record.Status
+	approvals := newApprovalNotifier(config, record)
+	submitted := submittedAt(record)
+	if submitted.IsZero() {
+		submitted = start
 	}
-
-	var lastStatus string
-	transactionId := activityResp.Activity.ReferenceId
+	giveUpAt := submitted.Add(maxTrackingAge)
+	// The first poll happens right away, so that a transfer awaiting
+	// approval is announced as soon as it is submitted.
+	delay := time.Duration(0)
 
 	for {
 		select {
-		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded && approvalUrl != "" {
-				zap.L().Info("transaction tracking window exceeded, continue on Prime",
-					zap.String("prime_url", approvalUrl),
-					zap.String("operation_id", operationId),
-				)
+		case <-root.Done():
+			zap.L().Info("transaction tracking stopped for shutdown, resuming after restart",
+				zap.String("transaction_id", transactionId),
+				zap.String("operation_id", operationId),
+			)
+			return
+		case <-window.C:
+			if time.Now().After(giveUpAt) {
+				stopTracking(client, ledger, config, record)
+				return
+			}
+			frequency = max(frequency, stalePollFrequency)
+			zap.L().Info("transaction tracking window exceeded, polling less often",
+				zap.String("prime_url", record.ApprovalUrl),
+				zap.Duration("frequency", frequency),
+				zap.String("operation_id", operationId),
+			)
+		case <-time.After(delay):
+			delay = frequency
+			if time.Now().After(giveUpAt) {
+				stopTracking(client, ledger, config, record)
+				return
 			}
-			return nil
-		case <-time.After(config.Daemon.TransferMonitorFrequency * time.Second):
-			var err error
-			lastStatus, err = logTransactionStatus(client, ctx, transactionId, lastStatus, operationId)
+			if transactionId == "" {
+				transactionId = resolveTransactionId(root, client, ledger, record)
+				if transactionId == "" {
+					continue
+				}
+			}
+
+			currentStatus, err := logTransactionStatus(client, root, transactionId, lastStatus, operationId)
 			if err != nil {
-				return err
+				continue
 			}
 
+			if currentStatus != lastStatus {
+				recordStatus(ledger, record, currentStatus)
+			}
+			lastStatus = currentStatus
+			approvals.observe(currentStatus, transactionId, time.Now().UTC())
+
 			if utils.LastStatusIsTerminal(lastStatus) {
-				return nil
+				metrics.ObserveTerminalStatus(record.RuleName, record.Request.Symbol, lastStatus)
+				metrics.TrackingDuration.WithLabelValues(record.RuleName, record.Request.Symbol, lastStatus).
+					Observe(time.Since(start).Seconds())
+				record.TransactionId = transactionId
+				notifyTerminalStatus(record, lastStatus)
+				advanceSequence(client, ledger, config, record.IdempotencyKey, lastStatus)
+				return
 			}
 		}
 	}
 }
+
+// stopTracking records a transfer that outlived maxTrackingAge as untracked
+// and cancels the rest of its sequence.
+func stopTracking(client utils.PrimeClient, ledger *store.Ledger, config *model.Config, record store.TransferRecord) {
+	zap.L().Warn("transfer not terminal after the tracking age limit, no longer tracking it",
+		zap.String("idempotency_key", record.IdempotencyKey),
+		zap.String("prime_url", record.ApprovalUrl),
+		zap.Duration("max_tracking_age", maxTrackingAge),
+		zap.String("operation_id", record.OperationId),
+	)
+	recordStatus(ledger, record, store.StatusUntracked)
+
+	event := transferEvent(notify.EventTransferFailed, notify.SeverityError, record)
+	event.Status = store.StatusUntracked
+	event.Message = "transfer no longer tracked, check its outcome in Prime"
+	notify.Send(event)
+	advanceSequence(client, ledger, config, record.IdempotencyKey, store.StatusUntracked)
+}
+
+// resolveTransactionId looks up the transaction created by the activity of
+// record and stores it in the ledger. It returns an empty string if the
+// activity cannot be read yet.
+func resolveTransactionId(ctx context.Context, client utils.PrimeClient, ledger *store.Ledger, record store.TransferRecord) string {
+	activityResp, err := client.GetActivity(ctx, &prime.GetActivityRequest{
+		PortfolioId: client.PortfolioId(),
+		Id:          record.ActivityId,
+	})
+	if err != nil {
+		zap.L().Error("could not get activity",
+			zap.String("activity_id", record.ActivityId),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	transactionId := activityResp.Activity.ReferenceId
+	if err := ledger.Update(record.IdempotencyKey, func(stored *store.TransferRecord) {
+		stored.TransactionId = transactionId
+	}); err != nil {
+		zap.L().Error("could not record transaction id",
+			zap.String("idempotency_key", record.IdempotencyKey),
+			zap.String("operation_id", record.OperationId),
+			zap.Error(err),
+		)
+	}
+	return transactionId
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/shopspring/decimal"
+	"sync"
+	"time"
+)
+
+var errVelocityLimit = errors.New("velocity limit reached")
+
+// velocityMu serializes limit checks with the ledger writes that follow them,
+// so concurrent rules cannot both pass a check for the last of a limit.
+var velocityMu sync.Mutex
+
+// CheckVelocityLimits returns an error wrapping errVelocityLimit if submitting
+// sequence on top of the transfers in history would exceed any of the limits
+// within its window ending at now. Transfers that failed, were rejected or
+// were cancelled do not count.
+func CheckVelocityLimits(
+	limits []model.VelocityLimit,
+	history []store.TransferRecord,
+	sequence []*prime.CreateWalletTransferRequest,
+	now time.Time,
+) error {
+	for _, limit := range limits {
+		since := now.Add(-limit.Window.Duration())
+
+		count := 0
+		amount := decimal.Zero
+		for _, record := range history {
+			if record.CreatedAt.Before(since) || !countsTowardsLimits(record.Status) {
+				continue
+			}
+			if limit.Asset != "" && record.Request.Symbol != limit.Asset {
+				continue
+			}
+			count++
+			if recordAmount, err := decimal.NewFromString(record.Request.Amount); err == nil {
+				amount = amount.Add(recordAmount)
+			}
+		}
+
+		for _, request := range sequence {
+			if limit.Asset != "" && request.Symbol != limit.Asset {
+				continue
+			}
+			count++
+			requestAmount, err := decimal.NewFromString(request.Amount)
+			if err != nil {
+				return fmt.Errorf("invalid amount %s: %w", request.Amount, err)
+			}
+			amount = amount.Add(requestAmount)
+		}
+
+		if limit.MaxCount > 0 && count > limit.MaxCount {
+			return fmt.Errorf("%w: %d transfers of %s within %s exceed the maximum of %d",
+				errVelocityLimit, count, limitAsset(limit), limit.Window.Duration(), limit.MaxCount)
+		}
+		if limit.MaxAmount != "" {
+			maxAmount, err := decimal.NewFromString(limit.MaxAmount)
+			if err != nil {
+				return fmt.Errorf("invalid velocity limit max amount '%s': %w", limit.MaxAmount, err)
+			}
+			if amount.GreaterThan(maxAmount) {
+				return fmt.Errorf("%w: %s %s within %s exceeds the maximum of %s",
+					errVelocityLimit, amount, limitAsset(limit), limit.Window.Duration(), maxAmount)
+			}
+		}
+	}
+	return nil
+}
+
+func countsTowardsLimits(status string) bool {
+	switch status {
+	case store.StatusSubmissionFailed, store.StatusCancelled, "TRANSACTION_REJECTED", "TRANSACTION_FAILED",
+		"TRANSACTION_CANCELLED", "TRANSACTION_EXPIRED":
+		return false
+	}
+	return true
+}
+
+func limitAsset(limit model.VelocityLimit) string {
+	if limit.Asset == "" {
+		return "all assets"
+	}
+	return limit.Asset
+}
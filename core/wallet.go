@@ -3,10 +3,14 @@ package core
 import (
 	"fmt"
 	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/metrics"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
 	"github.com/coinbase-samples/prime-sweeper-go/utils"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type WalletResponse struct {
@@ -14,22 +18,21 @@ type WalletResponse struct {
 	Symbol string `json:"symbol"`
 }
 
-var TradingWallets map[string]WalletResponse
-
 var minTransactionAmount, _ = decimal.NewFromString("0.00000001")
 
+// maxBalanceWorkers bounds the number of concurrent balance requests of a
+// single collection.
+const maxBalanceWorkers = 8
+
 type Balance struct {
 	Id                 string          `json:"id"`
 	Symbol             string          `json:"symbol"`
 	WithdrawableAmount decimal.Decimal `json:"withdrawable_amount"`
+	// Amount is the total balance, including funds on hold.
+	Amount decimal.Decimal `json:"amount"`
 }
 
-func CollectTradingWallets(config *model.Config) (map[string]WalletResponse, error) {
-	client, err := utils.GetClientFromEnv()
-	if err != nil {
-		return nil, fmt.Errorf("cannot get client from environment %w", err)
-	}
-
+func CollectTradingWallets(client utils.PrimeClient, config *model.Config) (map[string]WalletResponse, error) {
 	tradingWallets := make(map[string]WalletResponse)
 	uniqueAssets := make(map[string]struct{})
 	for _, wallet := range config.Wallets {
@@ -41,7 +44,7 @@ func CollectTradingWallets(config *model.Config) (map[string]WalletResponse, err
 
 	for asset := range uniqueAssets {
 		request := &prime.ListWalletsRequest{
-			PortfolioId: client.Credentials.PortfolioId,
+			PortfolioId: client.PortfolioId(),
 			Type:        "TRADING",
 			Symbols:     []string{asset},
 		}
@@ -75,43 +78,130 @@ func CollectTradingWallets(config *model.Config) (map[string]WalletResponse, err
 	return tradingWallets, nil
 }
 
-func CollectWalletBalances(config *model.Config, walletIds []string) (map[string]*Balance, error) {
+// WalletErrors maps the ID of every wallet whose balance could not be
+// collected to the reason.
+type WalletErrors map[string]error
+
+func (e WalletErrors) Error() string {
+	walletIds := make([]string, 0, len(e))
+	for walletId := range e {
+		walletIds = append(walletIds, walletId)
+	}
+	sort.Strings(walletIds)
+
+	messages := make([]string, 0, len(e))
+	for _, walletId := range walletIds {
+		messages = append(messages, e[walletId].Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// CollectWalletBalances fetches the balances of walletIds concurrently, using
+// at most maxBalanceWorkers requests at a time, and returns the ones with a
+// withdrawable balance. When some wallets fail, the balances of the others are
+// still returned alongside a WalletErrors error.
+func CollectWalletBalances(client utils.PrimeClient, config *model.Config, walletIds []string) (map[string]*Balance, error) {
+	return collectWalletBalances(client, config, walletIds, func(balance *Balance) bool {
+		return balance.WithdrawableAmount.GreaterThan(minTransactionAmount)
+	})
+}
+
+// collectWalletBalances is CollectWalletBalances returning the balances keep
+// accepts.
+func collectWalletBalances(
+	client utils.PrimeClient,
+	config *model.Config,
+	walletIds []string,
+	keep func(balance *Balance) bool,
+) (map[string]*Balance, error) {
 	nonEmptyWallets := make(map[string]*Balance)
+	failed := make(WalletErrors)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan string)
+
+	workers := min(maxBalanceWorkers, len(walletIds))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for walletId := range work {
+				balance, err := getWalletBalance(client, config, walletId)
+
+				mu.Lock()
+				if err != nil {
+					failed[walletId] = err
+				} else if keep(balance) {
+					nonEmptyWallets[walletId] = balance
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, walletId := range walletIds {
+		work <- walletId
+	}
+	close(work)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return nonEmptyWallets, failed
+	}
+	return nonEmptyWallets, nil
+}
+
+func getWalletBalance(client utils.PrimeClient, config *model.Config, walletId string) (*Balance, error) {
+	ctx, cancel := utils.GetContextWithTimeout(config)
+	defer cancel()
 
-	client, err := utils.GetClientFromEnv()
+	request := &prime.GetWalletBalanceRequest{
+		PortfolioId: client.PortfolioId(),
+		Id:          walletId,
+	}
+
+	response, err := client.GetWalletBalance(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get client from environment: %w", err)
+		return nil, fmt.Errorf("could not get balance for wallet ID %s: %w", walletId, err)
 	}
 
-	for _, walletId := range walletIds {
-		ctx, cancel := utils.GetContextWithTimeout(config)
-		request := &prime.GetWalletBalanceRequest{
-			PortfolioId: client.Credentials.PortfolioId,
-			Id:          walletId,
-		}
+	balance := response.Balance
+	amount, err := decimal.NewFromString(balance.WithdrawableAmount)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse amount for wallet ID %s: %w", walletId, err)
+	}
+	metrics.ObservedBalance.WithLabelValues(balance.Symbol, walletId).Set(amount.InexactFloat64())
 
-		response, err := client.GetWalletBalance(ctx, request)
-		cancel()
-		if err != nil {
-			return nil, fmt.Errorf("could not get balance for wallet ID %s: %v", walletId, err)
+	total := amount
+	if balance.Amount != "" {
+		if total, err = decimal.NewFromString(balance.Amount); err != nil {
+			return nil, fmt.Errorf("could not parse total amount for wallet ID %s: %w", walletId, err)
 		}
+	}
 
-		balance := response.Balance
-		amount, err := decimal.NewFromString(balance.WithdrawableAmount)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse amount for wallet ID %s: %v", walletId, err)
-		}
+	return &Balance{
+		Id:                 walletId,
+		Symbol:             balance.Symbol,
+		WithdrawableAmount: amount,
+		Amount:             total,
+	}, nil
+}
 
-		if amount.GreaterThan(minTransactionAmount) {
-			nonEmptyWallets[walletId] = &Balance{
-				Id:                 walletId,
-				Symbol:             balance.Symbol,
-				WithdrawableAmount: amount,
-			}
+// RuleWalletIds returns the IDs of every wallet a rule may move funds from or
+// to: its cold wallets and the trading wallets of their assets, or for a
+// cold-to-cold rule its cold wallets and destination wallets.
+func RuleWalletIds(portfolio *Portfolio, config *model.Config, rule model.Rule) []string {
+	walletIds := FilterWalletsByName(rule.Wallets, config)
+	if rule.Direction == string(model.ColdToCold) {
+		return append(walletIds, FilterWalletsByName(rule.DestinationWallets, config)...)
+	}
+	for _, asset := range GetAssetsForRule(rule, config) {
+		if wallet, exists := portfolio.TradingWallets[asset]; exists {
+			walletIds = append(walletIds, wallet.Id)
 		}
 	}
-
-	return nonEmptyWallets, nil
+	return walletIds
 }
 
 func GetAssetsForRule(rule model.Rule, config *model.Config) []string {
@@ -0,0 +1,59 @@
+package credentials
+
+import (
+	"context"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// retryAfterFailure is how long stale credentials keep being served after a
+// failed refresh before the source is asked again.
+const retryAfterFailure = 30 * time.Second
+
+type cached struct {
+	provider        Provider
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	credentials *prime.Credentials
+	nextRefresh time.Time
+}
+
+// Cached wraps provider so that it is asked at most once per refreshInterval.
+// When a refresh fails, the last credentials keep being served, so a source
+// that is briefly unavailable does not stop the sweeper; rotated credentials
+// take effect on the next successful refresh.
+func Cached(provider Provider, refreshInterval time.Duration) Provider {
+	return &cached{provider: provider, refreshInterval: refreshInterval}
+}
+
+func (c *cached) Credentials(ctx context.Context) (*prime.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.credentials != nil && now.Before(c.nextRefresh) {
+		return c.credentials, nil
+	}
+
+	credentials, err := c.provider.Credentials(ctx)
+	if err != nil {
+		if c.credentials == nil {
+			return nil, err
+		}
+		zap.L().Warn("cannot refresh credentials, using the previous ones", zap.Error(err))
+		c.nextRefresh = now.Add(min(retryAfterFailure, c.refreshInterval))
+		return c.credentials, nil
+	}
+
+	if c.credentials != nil && *credentials != *c.credentials {
+		zap.L().Info("credentials rotated", zap.String("portfolio_id", credentials.PortfolioId))
+	}
+	if c.credentials == nil || *credentials != *c.credentials {
+		c.credentials = credentials
+	}
+	c.nextRefresh = now.Add(c.refreshInterval)
+	return c.credentials, nil
+}
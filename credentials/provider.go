@@ -0,0 +1,90 @@
+// Package credentials loads Prime API credentials from the sources a
+// portfolio can be configured with.
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"time"
+)
+
+const (
+	SourceEnv   = "env"
+	SourceFile  = "file"
+	SourceExec  = "exec"
+	SourceVault = "vault"
+
+	DefaultEnv             = "PRIME_CREDENTIALS"
+	DefaultRefreshInterval = 300 * time.Second
+	defaultVaultTokenEnv   = "VAULT_TOKEN"
+)
+
+// Provider returns the current credentials of a portfolio.
+type Provider interface {
+	Credentials(ctx context.Context) (*prime.Credentials, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context) (*prime.Credentials, error)
+
+func (f ProviderFunc) Credentials(ctx context.Context) (*prime.Credentials, error) {
+	return f(ctx)
+}
+
+// FromConfig returns the cached provider configured for portfolio: its
+// credentials source when set, otherwise the variable named by
+// credentials_env, or PRIME_CREDENTIALS.
+func FromConfig(portfolio model.Portfolio) (Provider, error) {
+	source := portfolio.Credentials
+	if source == nil {
+		env := portfolio.CredentialsEnv
+		if env == "" {
+			env = DefaultEnv
+		}
+		source = &model.CredentialsSource{Type: SourceEnv, Env: env}
+	}
+
+	var provider Provider
+	switch source.Type {
+	case SourceEnv:
+		env := source.Env
+		if env == "" {
+			env = DefaultEnv
+		}
+		provider = Env(env)
+	case SourceFile:
+		provider = File(source.Path)
+	case SourceExec:
+		provider = Exec(source.Command)
+	case SourceVault:
+		tokenEnv := source.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = defaultVaultTokenEnv
+		}
+		provider = Vault(source.Address, source.Path, tokenEnv)
+	default:
+		return nil, fmt.Errorf("unknown credentials type '%s' for portfolio '%s'", source.Type, portfolio.Name)
+	}
+
+	refreshInterval := source.RefreshInterval.Duration()
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	return Cached(provider, refreshInterval), nil
+}
+
+// Parse decodes credentials in the JSON format of PRIME_CREDENTIALS. source
+// names where they came from, for error messages.
+func Parse(data []byte, source string) (*prime.Credentials, error) {
+	credentials := &prime.Credentials{}
+	if err := json.Unmarshal(data, credentials); err != nil {
+		return nil, fmt.Errorf("cannot unmarshall credentials from %s: %w", source, err)
+	}
+	if credentials.AccessKey == "" || credentials.SigningKey == "" || credentials.PortfolioId == "" {
+		return nil, fmt.Errorf("credentials from %s need accessKey, signingKey and portfolioId", source)
+	}
+	return credentials, nil
+}
@@ -0,0 +1,126 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	execTimeout  = 30 * time.Second
+	vaultTimeout = 10 * time.Second
+)
+
+// Env reads credentials from the environment variable name.
+func Env(name string) Provider {
+	return ProviderFunc(func(context.Context) (*prime.Credentials, error) {
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		return Parse([]byte(value), name)
+	})
+}
+
+// File reads credentials from the file at path, which must not be accessible
+// by group or others.
+func File(path string) Provider {
+	return ProviderFunc(func(context.Context) (*prime.Credentials, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read credentials file: %w", err)
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			return nil, fmt.Errorf("credentials file %s is accessible by group or others (mode %#o), expected 0600 or stricter", path, mode)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read credentials file: %w", err)
+		}
+		return Parse(data, path)
+	})
+}
+
+// Exec runs command and reads credentials from its standard output, in the
+// style of git credential helpers.
+func Exec(command []string) Provider {
+	return ProviderFunc(func(ctx context.Context) (*prime.Credentials, error) {
+		if len(command) == 0 {
+			return nil, fmt.Errorf("no credentials command configured")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, execTimeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("credentials command %s failed: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return Parse(stdout.Bytes(), "command "+command[0])
+	})
+}
+
+// Vault reads credentials from a HashiCorp Vault compatible secrets endpoint:
+// GET {address}/v1/{path} with the token held in tokenEnv. Both KV version 1
+// and version 2 responses are understood.
+func Vault(address, path, tokenEnv string) Provider {
+	url := strings.TrimRight(address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	client := &http.Client{Timeout: vaultTimeout}
+
+	return ProviderFunc(func(ctx context.Context) (*prime.Credentials, error) {
+		token := os.Getenv(tokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %s holding the vault token is not set", tokenEnv)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create vault request: %w", err)
+		}
+		request.Header.Set("X-Vault-Token", token)
+
+		response, err := client.Do(request)
+		if err != nil {
+			return nil, fmt.Errorf("cannot reach vault: %w", err)
+		}
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read vault response: %w", err)
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("vault returned %s for %s", response.Status, path)
+		}
+
+		var secret struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &secret); err != nil {
+			return nil, fmt.Errorf("cannot decode vault response: %w", err)
+		}
+
+		// KV version 2 nests the secret under data.data, next to data.metadata.
+		var versioned struct {
+			Data     json.RawMessage `json:"data"`
+			Metadata json.RawMessage `json:"metadata"`
+		}
+		data := secret.Data
+		if err := json.Unmarshal(data, &versioned); err == nil && versioned.Data != nil && versioned.Metadata != nil {
+			data = versioned.Data
+		}
+		return Parse(data, "vault "+path)
+	})
+}
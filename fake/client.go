@@ -0,0 +1,408 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"sort"
+	"sync"
+)
+
+const defaultTransactionStatus = "TRANSACTION_DONE"
+
+var _ utils.PrimeClient = (*PrimeClient)(nil)
+
+// PrimeClient is an in-memory implementation of utils.PrimeClient that allows
+// the sweeper to be exercised end to end without Prime credentials.
+type PrimeClient struct {
+	mu sync.Mutex
+
+	portfolioId       string
+	transactionStatus string
+
+	wallets      map[string]*prime.Wallet
+	balances     map[string]decimal.Decimal
+	held         map[string]decimal.Decimal
+	activities   map[string]*prime.Activity
+	transactions map[string]*prime.Transaction
+	responses    map[string]*prime.CreateWalletTransferResponse
+	transfers    []prime.CreateWalletTransferRequest
+	errors       map[string]error
+	failures     map[string][]error
+	calls        map[string]int
+	holds        map[string]chan struct{}
+}
+
+func NewPrimeClient(portfolioId string) *PrimeClient {
+	return &PrimeClient{
+		portfolioId:       portfolioId,
+		transactionStatus: defaultTransactionStatus,
+		wallets:           make(map[string]*prime.Wallet),
+		balances:          make(map[string]decimal.Decimal),
+		held:              make(map[string]decimal.Decimal),
+		activities:        make(map[string]*prime.Activity),
+		transactions:      make(map[string]*prime.Transaction),
+		responses:         make(map[string]*prime.CreateWalletTransferResponse),
+		errors:            make(map[string]error),
+		failures:          make(map[string][]error),
+		calls:             make(map[string]int),
+		holds:             make(map[string]chan struct{}),
+	}
+}
+
+// AddWallet registers a wallet of the given Prime type (e.g. TRADING or VAULT)
+// with an initial withdrawable balance.
+func (c *PrimeClient) AddWallet(id, walletType, symbol, balance string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wallets[id] = &prime.Wallet{
+		Id:     id,
+		Type:   walletType,
+		Name:   id,
+		Symbol: symbol,
+	}
+	c.balances[id] = decimal.RequireFromString(balance)
+}
+
+func (c *PrimeClient) SetBalance(walletId, balance string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.balances[walletId] = decimal.RequireFromString(balance)
+}
+
+// SetHeld puts funds on hold in a wallet on top of its withdrawable balance.
+// They count towards the total balance only.
+func (c *PrimeClient) SetHeld(walletId, held string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.held[walletId] = decimal.RequireFromString(held)
+}
+
+func (c *PrimeClient) Balance(walletId string) decimal.Decimal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.balances[walletId]
+}
+
+// SetTransactionStatus sets the status assigned to transactions created by
+// subsequent transfers.
+func (c *PrimeClient) SetTransactionStatus(status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.transactionStatus = status
+}
+
+// UpdateTransactionStatus changes the status of an existing transaction.
+func (c *PrimeClient) UpdateTransactionStatus(transactionId, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if transaction, exists := c.transactions[transactionId]; exists {
+		transaction.Status = status
+	}
+}
+
+// FailWith makes every call to the named method (e.g. "GetWalletBalance")
+// return err. Passing a nil error clears the failure.
+func (c *PrimeClient) FailWith(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		delete(c.errors, method)
+		return
+	}
+	c.errors[method] = err
+}
+
+// FailNext makes the next times calls to the named method return err before
+// it succeeds again, simulating a transient outage.
+func (c *PrimeClient) FailNext(method string, err error, times int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := 0; i < times; i++ {
+		c.failures[method] = append(c.failures[method], err)
+	}
+}
+
+// Hold blocks calls to the named method until the returned function is
+// called, simulating a slow Prime.
+func (c *PrimeClient) Hold(method string) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hold := make(chan struct{})
+	c.holds[method] = hold
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.holds[method] == hold {
+			delete(c.holds, method)
+		}
+		close(hold)
+	}
+}
+
+func (c *PrimeClient) wait(method string) {
+	c.mu.Lock()
+	hold := c.holds[method]
+	c.mu.Unlock()
+
+	if hold != nil {
+		<-hold
+	}
+}
+
+// Calls returns how many times the named method was called, including calls
+// that failed.
+func (c *PrimeClient) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[method]
+}
+
+// Transfers returns every accepted transfer request in submission order.
+func (c *PrimeClient) Transfers() []prime.CreateWalletTransferRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	transfers := make([]prime.CreateWalletTransferRequest, len(c.transfers))
+	copy(transfers, c.transfers)
+	return transfers
+}
+
+func (c *PrimeClient) PortfolioId() string {
+	return c.portfolioId
+}
+
+func (c *PrimeClient) ListWallets(
+	_ context.Context,
+	request *prime.ListWalletsRequest,
+) (*prime.ListWalletsResponse, error) {
+	c.wait("ListWallets")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("ListWallets", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]bool)
+	for _, symbol := range request.Symbols {
+		symbols[symbol] = true
+	}
+
+	response := &prime.ListWalletsResponse{Request: request}
+	for _, wallet := range c.wallets {
+		if request.Type != "" && wallet.Type != request.Type {
+			continue
+		}
+		if len(symbols) > 0 && !symbols[wallet.Symbol] {
+			continue
+		}
+		w := *wallet
+		response.Wallets = append(response.Wallets, &w)
+	}
+
+	sort.Slice(response.Wallets, func(i, j int) bool {
+		return response.Wallets[i].Id < response.Wallets[j].Id
+	})
+
+	return response, nil
+}
+
+func (c *PrimeClient) GetWallet(
+	_ context.Context,
+	request *prime.GetWalletRequest,
+) (*prime.GetWalletResponse, error) {
+	c.wait("GetWallet")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("GetWallet", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	wallet, exists := c.wallets[request.Id]
+	if !exists {
+		return nil, fmt.Errorf("wallet %s not found", request.Id)
+	}
+
+	w := *wallet
+	return &prime.GetWalletResponse{Wallet: &w, Request: request}, nil
+}
+
+func (c *PrimeClient) GetWalletBalance(
+	_ context.Context,
+	request *prime.GetWalletBalanceRequest,
+) (*prime.GetWalletBalanceResponse, error) {
+	c.wait("GetWalletBalance")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("GetWalletBalance", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	wallet, exists := c.wallets[request.Id]
+	if !exists {
+		return nil, fmt.Errorf("wallet %s not found", request.Id)
+	}
+
+	withdrawable := c.balances[request.Id]
+	return &prime.GetWalletBalanceResponse{
+		Balance: &prime.Balance{
+			Symbol:             wallet.Symbol,
+			Amount:             withdrawable.Add(c.held[request.Id]).String(),
+			Holds:              c.held[request.Id].String(),
+			WithdrawableAmount: withdrawable.String(),
+		},
+		Request: request,
+	}, nil
+}
+
+func (c *PrimeClient) CreateWalletTransfer(
+	_ context.Context,
+	request *prime.CreateWalletTransferRequest,
+) (*prime.CreateWalletTransferResponse, error) {
+	c.wait("CreateWalletTransfer")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("CreateWalletTransfer", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	if response, exists := c.responses[request.IdempotencyKey]; exists {
+		return response, nil
+	}
+
+	source, exists := c.wallets[request.SourceWalletId]
+	if !exists {
+		return nil, fmt.Errorf("source wallet %s not found", request.SourceWalletId)
+	}
+	destination, exists := c.wallets[request.DestinationWalletId]
+	if !exists {
+		return nil, fmt.Errorf("destination wallet %s not found", request.DestinationWalletId)
+	}
+	if source.Symbol != request.Symbol || destination.Symbol != request.Symbol {
+		return nil, fmt.Errorf("symbol mismatch for transfer of %s", request.Symbol)
+	}
+
+	amount, err := decimal.NewFromString(request.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %s: %w", request.Amount, err)
+	}
+	if !amount.IsPositive() || amount.GreaterThan(c.balances[source.Id]) {
+		return nil, fmt.Errorf("insufficient balance in wallet %s for %s", source.Id, request.Amount)
+	}
+
+	c.balances[source.Id] = c.balances[source.Id].Sub(amount)
+	c.balances[destination.Id] = c.balances[destination.Id].Add(amount)
+
+	transactionId := uuid.New().String()
+	activityId := uuid.New().String()
+
+	c.transactions[transactionId] = &prime.Transaction{
+		Id:          transactionId,
+		WalletId:    source.Id,
+		PortfolioId: c.portfolioId,
+		Type:        "TRANSFER",
+		Status:      c.transactionStatus,
+		Symbol:      request.Symbol,
+		Amount:      request.Amount,
+	}
+	c.activities[activityId] = &prime.Activity{
+		Id:          activityId,
+		ReferenceId: transactionId,
+		Symbols:     []string{request.Symbol},
+	}
+
+	response := &prime.CreateWalletTransferResponse{
+		ActivityId:    activityId,
+		ApprovalUrl:   fmt.Sprintf("https://prime.coinbase.com/portfolio/%s/activity/%s", c.portfolioId, activityId),
+		Symbol:        request.Symbol,
+		Amount:        request.Amount,
+		TransactionId: transactionId,
+		Request:       request,
+	}
+
+	c.responses[request.IdempotencyKey] = response
+	c.transfers = append(c.transfers, *request)
+
+	return response, nil
+}
+
+func (c *PrimeClient) GetActivity(
+	_ context.Context,
+	request *prime.GetActivityRequest,
+) (*prime.GetActivityResponse, error) {
+	c.wait("GetActivity")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("GetActivity", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	activity, exists := c.activities[request.Id]
+	if !exists {
+		return nil, fmt.Errorf("activity %s not found", request.Id)
+	}
+
+	a := *activity
+	return &prime.GetActivityResponse{Activity: &a, Request: request}, nil
+}
+
+func (c *PrimeClient) GetTransaction(
+	_ context.Context,
+	request *prime.GetTransactionRequest,
+) (*prime.GetTransactionResponse, error) {
+	c.wait("GetTransaction")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkRequest("GetTransaction", request.PortfolioId); err != nil {
+		return nil, err
+	}
+
+	transaction, exists := c.transactions[request.TransactionId]
+	if !exists {
+		return nil, fmt.Errorf("transaction %s not found", request.TransactionId)
+	}
+
+	t := *transaction
+	return &prime.GetTransactionResponse{Transaction: &t, Request: request}, nil
+}
+
+func (c *PrimeClient) checkRequest(method, portfolioId string) error {
+	c.calls[method]++
+	if failures := c.failures[method]; len(failures) > 0 {
+		c.failures[method] = failures[1:]
+		return failures[0]
+	}
+	if err, exists := c.errors[method]; exists {
+		return err
+	}
+	if portfolioId != c.portfolioId {
+		return fmt.Errorf("portfolio %s not found", portfolioId)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"time"
+)
+
+type instrumentedClient struct {
+	next utils.PrimeClient
+}
+
+// InstrumentClient wraps client so that the latency and errors of every
+// Prime call are recorded.
+func InstrumentClient(client utils.PrimeClient) utils.PrimeClient {
+	return &instrumentedClient{next: client}
+}
+
+func observe(method string, start time.Time, err error) {
+	PrimeRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		PrimeRequestErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (c *instrumentedClient) PortfolioId() string {
+	return c.next.PortfolioId()
+}
+
+func (c *instrumentedClient) ListWallets(
+	ctx context.Context,
+	request *prime.ListWalletsRequest,
+) (response *prime.ListWalletsResponse, err error) {
+	defer func(start time.Time) { observe("ListWallets", start, err) }(time.Now())
+	return c.next.ListWallets(ctx, request)
+}
+
+func (c *instrumentedClient) GetWallet(
+	ctx context.Context,
+	request *prime.GetWalletRequest,
+) (response *prime.GetWalletResponse, err error) {
+	defer func(start time.Time) { observe("GetWallet", start, err) }(time.Now())
+	return c.next.GetWallet(ctx, request)
+}
+
+func (c *instrumentedClient) GetWalletBalance(
+	ctx context.Context,
+	request *prime.GetWalletBalanceRequest,
+) (response *prime.GetWalletBalanceResponse, err error) {
+	defer func(start time.Time) { observe("GetWalletBalance", start, err) }(time.Now())
+	return c.next.GetWalletBalance(ctx, request)
+}
+
+func (c *instrumentedClient) CreateWalletTransfer(
+	ctx context.Context,
+	request *prime.CreateWalletTransferRequest,
+) (response *prime.CreateWalletTransferResponse, err error) {
+	defer func(start time.Time) { observe("CreateWalletTransfer", start, err) }(time.Now())
+	return c.next.CreateWalletTransfer(ctx, request)
+}
+
+func (c *instrumentedClient) GetActivity(
+	ctx context.Context,
+	request *prime.GetActivityRequest,
+) (response *prime.GetActivityResponse, err error) {
+	defer func(start time.Time) { observe("GetActivity", start, err) }(time.Now())
+	return c.next.GetActivity(ctx, request)
+}
+
+func (c *instrumentedClient) GetTransaction(
+	ctx context.Context,
+	request *prime.GetTransactionRequest,
+) (response *prime.GetTransactionResponse, err error) {
+	defer func(start time.Time) { observe("GetTransaction", start, err) }(time.Now())
+	return c.next.GetTransaction(ctx, request)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "sweeper"
+
+// Registry holds every sweeper metric, plus the standard Go and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	RuleExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_executions_total",
+		Help:      "Number of rule executions.",
+	}, []string{"rule"})
+
+	RuleRunsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_runs_skipped_total",
+		Help:      "Number of rule runs skipped because the previous run had not finished.",
+	}, []string{"rule"})
+
+	RuleExecutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rule_execution_duration_seconds",
+		Help:      "Time spent collecting balances and initiating transfers for a rule.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"rule"})
+
+	ObservedBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "observed_balance",
+		Help:      "Last withdrawable balance observed for a wallet.",
+	}, []string{"asset", "wallet_id"})
+
+	TransfersInitiated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_initiated_total",
+		Help:      "Number of transfers accepted by Prime.",
+	}, []string{"rule", "asset"})
+
+	TransfersCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_completed_total",
+		Help:      "Number of transfers that reached TRANSACTION_DONE.",
+	}, []string{"rule", "asset"})
+
+	TransfersFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_failed_total",
+		Help:      "Number of transfers that could not be submitted or reached TRANSACTION_FAILED.",
+	}, []string{"rule", "asset"})
+
+	TransfersRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_rejected_total",
+		Help:      "Number of transfers that reached TRANSACTION_REJECTED.",
+	}, []string{"rule", "asset"})
+
+	TransfersOverLimit = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_over_limit_total",
+		Help:      "Number of transfers skipped because they would exceed a velocity limit.",
+	}, []string{"rule", "asset"})
+
+	SweptAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "swept_amount_total",
+		Help:      "Sum of the amounts of transfers accepted by Prime, in units of the asset.",
+	}, []string{"rule", "asset"})
+
+	PrimeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "prime_request_duration_seconds",
+		Help:      "Latency of Prime API calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	PrimeRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prime_request_errors_total",
+		Help:      "Number of Prime API calls that returned an error.",
+	}, []string{"method"})
+
+	TrackingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transfer_tracking_duration_seconds",
+		Help:      "Time spent tracking a transfer until it reached a terminal status.",
+		Buckets:   []float64{10, 30, 60, 300, 900, 1800, 3600, 7200, 14400},
+	}, []string{"rule", "asset", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RuleExecutions,
+		RuleRunsSkipped,
+		RuleExecutionDuration,
+		ObservedBalance,
+		TransfersInitiated,
+		TransfersCompleted,
+		TransfersFailed,
+		TransfersRejected,
+		TransfersOverLimit,
+		SweptAmount,
+		PrimeRequestDuration,
+		PrimeRequestErrors,
+		TrackingDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveTerminalStatus counts a transfer that reached a terminal Prime status.
+func ObserveTerminalStatus(ruleName, asset, status string) {
+	switch status {
+	case "TRANSACTION_DONE":
+		TransfersCompleted.WithLabelValues(ruleName, asset).Inc()
+	case "TRANSACTION_REJECTED":
+		TransfersRejected.WithLabelValues(ruleName, asset).Inc()
+	case "TRANSACTION_FAILED", "TRANSACTION_CANCELLED", "TRANSACTION_EXPIRED":
+		TransfersFailed.WithLabelValues(ruleName, asset).Inc()
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+// Seconds is a duration written in the config as a whole number of seconds.
+type Seconds int64
+
+// Minutes is a duration written in the config as a whole number of minutes.
+type Minutes int64
+
+func (s Seconds) Duration() time.Duration {
+	return time.Duration(s) * time.Second
+}
+
+func (m Minutes) Duration() time.Duration {
+	return time.Duration(m) * time.Minute
+}
+
+func (s *Seconds) UnmarshalYAML(node *yaml.Node) error {
+	value, err := decodeWholeNumber(node, "seconds")
+	if err != nil {
+		return err
+	}
+	*s = Seconds(value)
+	return nil
+}
+
+func (m *Minutes) UnmarshalYAML(node *yaml.Node) error {
+	value, err := decodeWholeNumber(node, "minutes")
+	if err != nil {
+		return err
+	}
+	*m = Minutes(value)
+	return nil
+}
+
+// decodeWholeNumber decodes node as an integer. Any other value is returned as
+// a *yaml.TypeError, which the decoder collects with its own errors instead of
+// stopping.
+func decodeWholeNumber(node *yaml.Node, unit string) (int64, error) {
+	var value int64
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!int" && node.Decode(&value) == nil {
+		return value, nil
+	}
+
+	message := fmt.Sprintf("line %d: '%s' must be a number of %s", node.Line, node.Value, unit)
+	if _, err := time.ParseDuration(node.Value); err == nil {
+		message += ", without a unit"
+	}
+	return 0, &yaml.TypeError{Errors: []string{message}}
+}
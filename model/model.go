@@ -5,43 +5,162 @@ import (
 )
 
 type Config struct {
-	Daemon  DaemonConfig `yaml:"daemon"`
-	Rules   []Rule       `yaml:"rules"`
-	Wallets []Wallet     `yaml:"wallets"`
+	Daemon        DaemonConfig       `yaml:"daemon" json:"daemon"`
+	Portfolios    []Portfolio        `yaml:"portfolios" json:"portfolios"` // Optional
+	Rules         []Rule             `yaml:"rules" json:"rules"`
+	Wallets       []Wallet           `yaml:"wallets" json:"wallets"`
+	Limits        []VelocityLimit    `yaml:"limits" json:"limits"`               // Optional
+	Notifications NotificationConfig `yaml:"notifications" json:"notifications"` // Optional
+}
+
+type NotificationConfig struct {
+	WebhookUrl                string             `yaml:"webhook_url" json:"webhook_url"` // Optional, approval events only
+	ApprovalReminderFrequency Seconds            `yaml:"approval_reminder_frequency" json:"approval_reminder_frequency"`
+	Sinks                     []NotificationSink `yaml:"sinks" json:"sinks"` // Optional
+}
+
+// NotificationSink is a destination for notifications. Secrets are read from
+// the named environment variables so they never appear in the config.
+type NotificationSink struct {
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type" json:"type"`                 // webhook, slack or smtp
+	MinSeverity string   `yaml:"min_severity" json:"min_severity"` // Optional, defaults to info
+	Rules       []string `yaml:"rules" json:"rules"`               // Optional, defaults to every rule
+	Events      []string `yaml:"events" json:"events"`             // Optional, defaults to every event
+	Url         string   `yaml:"url" json:"url"`                   // webhook and slack
+	SecretEnv   string   `yaml:"secret_env" json:"secret_env"`     // Optional, webhook HMAC secret
+	SmtpHost    string   `yaml:"smtp_host" json:"smtp_host"`
+	SmtpPort    int      `yaml:"smtp_port" json:"smtp_port"`
+	Username    string   `yaml:"username" json:"username"`         // Optional
+	PasswordEnv string   `yaml:"password_env" json:"password_env"` // Optional
+	From        string   `yaml:"from" json:"from"`
+	To          []string `yaml:"to" json:"to"`
+}
+
+// VelocityLimit caps what all rules together may transfer within a rolling
+// window. MaxAmount requires an asset; MaxCount applies to every asset when
+// no asset is set.
+type VelocityLimit struct {
+	Asset     string  `yaml:"asset" json:"asset"` // Optional for max_count
+	Window    Seconds `yaml:"window" json:"window"`
+	MaxAmount string  `yaml:"max_amount" json:"max_amount"` // Optional
+	MaxCount  int     `yaml:"max_count" json:"max_count"`   // Optional
+}
+
+type Portfolio struct {
+	Name           string `yaml:"name" json:"name"`
+	Description    string `yaml:"description" json:"description"`         // Optional
+	CredentialsEnv string `yaml:"credentials_env" json:"credentials_env"` // Optional, defaults to PRIME_CREDENTIALS
+	// Credentials overrides credentials_env with another source.
+	Credentials *CredentialsSource `yaml:"credentials" json:"credentials"`
+}
+
+// CredentialsSource selects where a portfolio's API credentials are read
+// from. Every source yields the JSON format of PRIME_CREDENTIALS.
+type CredentialsSource struct {
+	Type     string   `yaml:"type" json:"type"`           // env, file, exec or vault
+	Env      string   `yaml:"env" json:"env"`             // env: variable name
+	Path     string   `yaml:"path" json:"path"`           // file: file path; vault: secret path
+	Command  []string `yaml:"command" json:"command"`     // exec: command and arguments
+	Address  string   `yaml:"address" json:"address"`     // vault: server address
+	TokenEnv string   `yaml:"token_env" json:"token_env"` // vault: variable holding the token, defaults to VAULT_TOKEN
+	// RefreshInterval is the number of seconds credentials are cached before
+	// being read again, which is how rotated credentials are picked up.
+	RefreshInterval Seconds `yaml:"refresh_interval" json:"refresh_interval"`
 }
 
 type DaemonConfig struct {
-	ContextTimeoutDuration         int           `yaml:"context_timeout_duration"`
-	TransferMonitorFrequency       time.Duration `yaml:"transfer_monitor_frequency"`
-	TransferMonitorTimeoutDuration time.Duration `yaml:"transfer_monitor_timeout_duration"`
+	ContextTimeoutDuration         int             `yaml:"context_timeout_duration" json:"context_timeout_duration"`
+	TransferMonitorFrequency       Seconds         `yaml:"transfer_monitor_frequency" json:"transfer_monitor_frequency"`
+	TransferMonitorTimeoutDuration Minutes         `yaml:"transfer_monitor_timeout_duration" json:"transfer_monitor_timeout_duration"` // Minutes of fast polling, not a timeout
+	DryRun                         bool            `yaml:"dry_run" json:"dry_run"`
+	ThresholdPollFrequency         Seconds         `yaml:"threshold_poll_frequency" json:"threshold_poll_frequency"`
+	LedgerPath                     string          `yaml:"ledger_path" json:"ledger_path"`
+	AdminAddress                   string          `yaml:"admin_address" json:"admin_address"`
+	MetricsAddress                 string          `yaml:"metrics_address" json:"metrics_address"`
+	ConfigReloadFrequency          Seconds         `yaml:"config_reload_frequency" json:"config_reload_frequency"`
+	ShutdownTimeout                Seconds         `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+	Retry                          RetryConfig     `yaml:"retry" json:"retry"`
+	RateLimit                      RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// RateLimitConfig caps the rate of Prime requests across all rules and
+// portfolios. Zero values fall back to the defaults.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second" json:"requests_per_second"`
+	Burst             int     `yaml:"burst" json:"burst"`
+}
+
+// RetryConfig controls how failed Prime calls are retried. Zero values fall
+// back to the defaults; max_attempts of 1 disables retries.
+type RetryConfig struct {
+	MaxAttempts      int `yaml:"max_attempts" json:"max_attempts"`
+	InitialBackoffMs int `yaml:"initial_backoff_ms" json:"initial_backoff_ms"`
+	MaxBackoffMs     int `yaml:"max_backoff_ms" json:"max_backoff_ms"`
 }
 
 type Rule struct {
-	Direction   string   `yaml:"direction" json:"direction"`
-	Name        string   `yaml:"name" json:"name"`
-	Description string   `yaml:"description" json:"description"` // Optional
-	Schedule    string   `yaml:"schedule" json:"schedule"`
-	Wallets     []string `yaml:"wallets" json:"wallets"`
+	Direction         string            `yaml:"direction" json:"direction"`
+	Name              string            `yaml:"name" json:"name"`
+	Portfolio         string            `yaml:"portfolio" json:"portfolio"`     // Optional with a single portfolio
+	Description       string            `yaml:"description" json:"description"` // Optional
+	Schedule          string            `yaml:"schedule" json:"schedule"`
+	Wallets           []string          `yaml:"wallets" json:"wallets"`
+	RetainAmount      string            `yaml:"retain_amount" json:"retain_amount"`             // Optional
+	RetainPercentage  string            `yaml:"retain_percentage" json:"retain_percentage"`     // Optional
+	Thresholds        map[string]string `yaml:"thresholds" json:"thresholds"`                   // Optional
+	MinSweepAmount    string            `yaml:"min_sweep_amount" json:"min_sweep_amount"`       // Optional
+	TargetBalances    map[string]string `yaml:"target_balances" json:"target_balances"`         // Optional
+	Allocation        string            `yaml:"allocation" json:"allocation"`                   // Optional
+	MaxTransferAmount string            `yaml:"max_transfer_amount" json:"max_transfer_amount"` // Optional
+	// DestinationWallets receive what a cold_custody_to_cold_custody rule
+	// drains from its wallets. Without them the rule rebalances its wallets
+	// to the shares given by their weights.
+	DestinationWallets []string `yaml:"destination_wallets" json:"destination_wallets"` // Optional
 }
 
 type Wallet struct {
-	Name        string `yaml:"name" json:"name"`
-	Asset       string `yaml:"asset" json:"asset"`
-	Description string `yaml:"description" json:"description"` // Optional
-	Type        string `yaml:"type" json:"type"`
-	WalletId    string `yaml:"wallet_id" json:"wallet_id"`
+	Name              string `yaml:"name" json:"name"`
+	Portfolio         string `yaml:"portfolio" json:"portfolio"` // Optional with a single portfolio
+	Asset             string `yaml:"asset" json:"asset"`
+	Description       string `yaml:"description" json:"description"` // Optional
+	Type              string `yaml:"type" json:"type"`
+	WalletId          string `yaml:"wallet_id" json:"wallet_id"`
+	RetainAmount      string `yaml:"retain_amount" json:"retain_amount"`             // Optional
+	RetainPercentage  string `yaml:"retain_percentage" json:"retain_percentage"`     // Optional
+	Weight            string `yaml:"weight" json:"weight"`                           // Optional, for weighted allocation
+	Cap               string `yaml:"cap" json:"cap"`                                 // Optional, for fill_to_cap allocation
+	MaxTransferAmount string `yaml:"max_transfer_amount" json:"max_transfer_amount"` // Optional
 }
 
 const (
 	HotToCold TransferDirection = "trading_to_cold_custody"
 	ColdToHot TransferDirection = "cold_custody_to_trading"
+	// ColdToCold moves funds between cold custody wallets of the same asset.
+	ColdToCold TransferDirection = "cold_custody_to_cold_custody"
 )
 
 type TransferDirection string
 
+// ColdCustodyWallet is the only supported wallet type.
+const ColdCustodyWallet = "cold_custody"
+
+// Allocation strategies spread a hot-to-cold sweep across the cold wallets of
+// a rule that hold the same asset.
+const (
+	AllocationFirst        = "first"
+	AllocationWeighted     = "weighted"
+	AllocationFillToCap    = "fill_to_cap"
+	AllocationRoundRobin   = "round_robin"
+	AllocationLeastBalance = "least_balance"
+)
+
 type TransferDetails struct {
 	Direction   TransferDirection
 	WalletNames []string
 	OperationId string
 	RuleName    string
+	ScheduledAt time.Time
+	DryRun      bool
+	Assets      []string // Optional, restricts a trading_to_cold_custody run to these assets
 }
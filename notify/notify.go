@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// EventRuleStarted and EventRuleFinished bracket every rule execution.
+	EventRuleStarted  = "rule_started"
+	EventRuleFinished = "rule_finished"
+	// EventRuleSkipped is sent when a run is skipped because the previous
+	// run of the same rule is still in progress.
+	EventRuleSkipped = "rule_skipped"
+	// EventRuleError is sent when a rule execution or one of its transfers
+	// could not be prepared.
+	EventRuleError = "rule_error"
+	// EventTransferInitiated is sent once Prime has accepted a transfer.
+	EventTransferInitiated = "transfer_initiated"
+	// EventTransferSkipped is sent when a transfer would breach a velocity
+	// limit.
+	EventTransferSkipped = "transfer_skipped"
+	// EventTransferCompleted, EventTransferRejected and EventTransferFailed
+	// report the terminal status of a transfer. Failed also covers transfers
+	// Prime did not accept.
+	EventTransferCompleted = "transfer_completed"
+	EventTransferRejected  = "transfer_rejected"
+	EventTransferFailed    = "transfer_failed"
+	// EventApprovalPending is sent when a transfer is first seen awaiting
+	// consensus approval in Prime.
+	EventApprovalPending = "approval_pending"
+	// EventApprovalReminder is sent periodically while a transfer keeps
+	// awaiting approval.
+	EventApprovalReminder = "approval_reminder"
+)
+
+// EventTypes lists every event type, for validating sink filters.
+var EventTypes = []string{
+	EventRuleStarted,
+	EventRuleFinished,
+	EventRuleSkipped,
+	EventRuleError,
+	EventTransferInitiated,
+	EventTransferSkipped,
+	EventTransferCompleted,
+	EventTransferRejected,
+	EventTransferFailed,
+	EventApprovalPending,
+	EventApprovalReminder,
+}
+
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+var severityRanks = map[string]int{
+	SeverityInfo:    0,
+	SeverityWarning: 1,
+	SeverityError:   2,
+}
+
+// ValidSeverity reports whether severity is one of the known severities.
+func ValidSeverity(severity string) bool {
+	_, exists := severityRanks[severity]
+	return exists
+}
+
+const deliveryTimeout = 10 * time.Second
+
+// Event describes something in the life of a sweep. Transfer fields are empty
+// for rule-level events.
+type Event struct {
+	Type                string     `json:"type"`
+	Severity            string     `json:"severity"`
+	RuleName            string     `json:"rule"`
+	OperationId         string     `json:"operation_id,omitempty"`
+	Message             string     `json:"message,omitempty"`
+	Error               string     `json:"error,omitempty"`
+	IdempotencyKey      string     `json:"idempotency_key,omitempty"`
+	TransactionId       string     `json:"transaction_id,omitempty"`
+	Status              string     `json:"status,omitempty"`
+	Symbol              string     `json:"symbol,omitempty"`
+	Amount              string     `json:"amount,omitempty"`
+	SourceWalletId      string     `json:"source_wallet_id,omitempty"`
+	DestinationWalletId string     `json:"destination_wallet_id,omitempty"`
+	ApprovalUrl         string     `json:"approval_url,omitempty"`
+	PendingSince        *time.Time `json:"pending_since,omitempty"` // Approval events only
+	Time                time.Time  `json:"time"`
+}
+
+// Summary renders the event as a single line for human readers.
+func (e Event) Summary() string {
+	summary := fmt.Sprintf("[%s] %s: rule %s", e.Severity, e.Type, e.RuleName)
+	if e.Amount != "" {
+		summary += fmt.Sprintf(", %s %s from %s to %s", e.Amount, e.Symbol, e.SourceWalletId, e.DestinationWalletId)
+	}
+	if e.Status != "" {
+		summary += fmt.Sprintf(", status %s", e.Status)
+	}
+	if e.Message != "" {
+		summary += ", " + e.Message
+	}
+	if e.Error != "" {
+		summary += ", error: " + e.Error
+	}
+	if e.ApprovalUrl != "" {
+		summary += ", approve at " + e.ApprovalUrl
+	}
+	return summary
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+var (
+	mu sync.RWMutex
+	// queues holds one delivery queue per sink of the current notifier.
+	queues []*sinkQueue
+)
+
+// SetNotifier replaces the notifier used by Send. A nil notifier disables
+// notifications. Every sink of a Router gets its own queue; any other
+// notifier is a single sink. Events already queued for the previous notifier
+// are still delivered.
+func SetNotifier(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, q := range queues {
+		q.close()
+	}
+	queues = nil
+	if n == nil {
+		return
+	}
+
+	routes := []route{{name: "notifier", sink: n, minSeverity: SeverityInfo}}
+	if router, ok := n.(*Router); ok {
+		routes = router.routes
+	}
+	for _, r := range routes {
+		queues = append(queues, startQueue(r))
+	}
+}
+
+// Send queues event for every sink that accepts it and returns without
+// waiting, so that a slow or unavailable sink never holds up transfers. Each
+// sink receives its events in the order they were sent. Delivery failures are
+// logged.
+func Send(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, q := range queues {
+		if q.route.accepts(event) {
+			q.push(event)
+		}
+	}
+}
+
+// Flush waits until every notification sent so far has been delivered or ctx
+// is done, so that a process can exit without losing notifications.
+func Flush(ctx context.Context) error {
+	mu.RLock()
+	var pending []<-chan struct{}
+	for _, q := range queues {
+		flushed, err := q.flush(ctx)
+		if err != nil {
+			mu.RUnlock()
+			return err
+		}
+		pending = append(pending, flushed)
+	}
+	mu.RUnlock()
+
+	for _, flushed := range pending {
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+// queueSize is how many notifications a sink may fall behind before new ones
+// are dropped.
+const queueSize = 256
+
+// queued is an event waiting for delivery, or a Flush marker closed once
+// every event queued before it has been delivered.
+type queued struct {
+	event   Event
+	flushed chan struct{}
+}
+
+// sinkQueue delivers the events accepted by one route, one at a time and in
+// the order they were sent, so that a sink never sees a transfer complete
+// before it was initiated. Every sink has its own queue, so a slow sink only
+// holds up itself.
+type sinkQueue struct {
+	route route
+	items chan queued
+}
+
+func startQueue(r route) *sinkQueue {
+	q := &sinkQueue{route: r, items: make(chan queued, queueSize)}
+	go q.run()
+	return q
+}
+
+// run delivers until the queue is closed and drained.
+func (q *sinkQueue) run() {
+	for item := range q.items {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		q.deliver(item.event)
+	}
+}
+
+func (q *sinkQueue) deliver(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	if err := q.route.sink.Notify(ctx, event); err != nil {
+		zap.L().Error("could not deliver notification",
+			zap.String("sink", q.route.name),
+			zap.String("type", event.Type),
+			zap.String("rule", event.RuleName),
+			zap.String("idempotency_key", event.IdempotencyKey),
+			zap.String("operation_id", event.OperationId),
+			zap.Error(err),
+		)
+	}
+}
+
+// push queues event without blocking. It is dropped when the sink is
+// queueSize events behind.
+func (q *sinkQueue) push(event Event) {
+	select {
+	case q.items <- queued{event: event}:
+	default:
+		zap.L().Error("notification queue is full, dropping notification",
+			zap.String("sink", q.route.name),
+			zap.String("type", event.Type),
+			zap.String("rule", event.RuleName),
+			zap.String("operation_id", event.OperationId),
+		)
+	}
+}
+
+// flush queues a marker behind the pending events and returns the channel
+// closed once they are delivered.
+func (q *sinkQueue) flush(ctx context.Context) (<-chan struct{}, error) {
+	flushed := make(chan struct{})
+	select {
+	case q.items <- queued{flushed: flushed}:
+		return flushed, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// close stops the queue once the events already queued are delivered.
+func (q *sinkQueue) close() {
+	close(q.items)
+}
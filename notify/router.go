@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"os"
+)
+
+const (
+	SinkWebhook = "webhook"
+	SinkSlack   = "slack"
+	SinkSmtp    = "smtp"
+)
+
+// route delivers the events that pass its filters to one sink.
+type route struct {
+	name        string
+	sink        Notifier
+	minSeverity string
+	rules       map[string]bool
+	events      map[string]bool
+}
+
+func (r route) accepts(event Event) bool {
+	if severityRanks[event.Severity] < severityRanks[r.minSeverity] {
+		return false
+	}
+	if len(r.rules) > 0 && !r.rules[event.RuleName] {
+		return false
+	}
+	if len(r.events) > 0 && !r.events[event.Type] {
+		return false
+	}
+	return true
+}
+
+// Router fans events out to every sink whose filters accept them.
+type Router struct {
+	routes []route
+}
+
+func (r *Router) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, route := range r.routes {
+		if !route.accepts(event) {
+			continue
+		}
+		if err := route.sink.Notify(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("sink '%s': %w", route.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FromConfig builds the notifier described by config. It returns nil when no
+// sink is configured. The legacy webhook_url setting becomes an unsigned
+// webhook sink that only receives approval events.
+func FromConfig(config model.NotificationConfig) (Notifier, error) {
+	router := &Router{}
+
+	if config.WebhookUrl != "" {
+		router.routes = append(router.routes, route{
+			name:        "webhook_url",
+			sink:        NewWebhookNotifier(config.WebhookUrl, ""),
+			minSeverity: SeverityInfo,
+			events:      map[string]bool{EventApprovalPending: true, EventApprovalReminder: true},
+		})
+	}
+
+	for _, sinkConfig := range config.Sinks {
+		sink, err := newSink(sinkConfig)
+		if err != nil {
+			return nil, fmt.Errorf("sink '%s': %w", sinkConfig.Name, err)
+		}
+
+		r := route{
+			name:        sinkConfig.Name,
+			sink:        sink,
+			minSeverity: sinkConfig.MinSeverity,
+			rules:       toSet(sinkConfig.Rules),
+			events:      toSet(sinkConfig.Events),
+		}
+		if r.minSeverity == "" {
+			r.minSeverity = SeverityInfo
+		}
+		router.routes = append(router.routes, r)
+	}
+
+	if len(router.routes) == 0 {
+		return nil, nil
+	}
+	return router, nil
+}
+
+func newSink(config model.NotificationSink) (Notifier, error) {
+	switch config.Type {
+	case SinkWebhook:
+		secret := ""
+		if config.SecretEnv != "" {
+			secret = os.Getenv(config.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("secret variable %s is not set", config.SecretEnv)
+			}
+		}
+		return NewWebhookNotifier(config.Url, secret), nil
+	case SinkSlack:
+		return NewSlackNotifier(config.Url), nil
+	case SinkSmtp:
+		password := ""
+		if config.PasswordEnv != "" {
+			password = os.Getenv(config.PasswordEnv)
+		}
+		return NewSmtpNotifier(config.SmtpHost, config.SmtpPort, config.Username, password, config.From, config.To), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type '%s'", config.Type)
+	}
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
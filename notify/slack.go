@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// SlackNotifier posts events to a Slack-compatible incoming webhook.
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{url: url, client: &http.Client{}}
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]string{"text": event.Summary()})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.client, n.url, body, nil)
+}
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// StatusPending is recorded before a transfer is submitted to Prime.
+	StatusPending = "PENDING"
+	// StatusSubmitted is recorded once Prime has accepted a transfer.
+	StatusSubmitted = "SUBMITTED"
+	// StatusSubmissionFailed is recorded when Prime rejected the submission.
+	StatusSubmissionFailed = "SUBMISSION_FAILED"
+	// StatusQueued is recorded for a chunk waiting on the previous chunk of
+	// its sequence.
+	StatusQueued = "QUEUED"
+	// StatusCancelled is recorded for a queued chunk whose sequence stopped
+	// because an earlier chunk did not complete.
+	StatusCancelled = "CANCELLED"
+	// StatusUntracked is recorded for a submitted transfer that did not reach
+	// a terminal status within the tracking age limit. The sweeper no longer
+	// follows it; its outcome has to be checked in Prime.
+	StatusUntracked = "UNTRACKED"
+)
+
+var (
+	transfersBucket = []byte("transfers")
+	handoffBucket   = []byte("handoff")
+	handoffKey      = []byte("latest")
+)
+
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// readOnlyTimeout bounds how long OpenReadOnly waits for a writer to release
+// the ledger.
+const readOnlyTimeout = time.Second
+
+type StatusTransition struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// TransferRecord is the persisted state of a single transfer, keyed by its
+// idempotency key.
+type TransferRecord struct {
+	IdempotencyKey string                            `json:"idempotency_key"`
+	OperationId    string                            `json:"operation_id"`
+	RuleName       string                            `json:"rule_name"`
+	Request        prime.CreateWalletTransferRequest `json:"request"`
+	ActivityId     string                            `json:"activity_id"`
+	TransactionId  string                            `json:"transaction_id"`
+	ApprovalUrl    string                            `json:"approval_url"`
+	DependsOn      string                            `json:"depends_on,omitempty"`
+	Status         string                            `json:"status"`
+	Transitions    []StatusTransition                `json:"transitions"`
+	CreatedAt      time.Time                         `json:"created_at"`
+	UpdatedAt      time.Time                         `json:"updated_at"`
+}
+
+func (r *TransferRecord) IsTerminal() bool {
+	switch r.Status {
+	case StatusSubmissionFailed, StatusCancelled, StatusUntracked:
+		return true
+	}
+	return utils.LastStatusIsTerminal(r.Status)
+}
+
+// SetStatus records a status transition. Repeating the current status is a
+// no-op.
+func (r *TransferRecord) SetStatus(status string) {
+	if r.Status == status {
+		return
+	}
+	now := time.Now().UTC()
+	r.Status = status
+	r.Transitions = append(r.Transitions, StatusTransition{Status: status, At: now})
+	r.UpdatedAt = now
+}
+
+// Ledger is a file-backed store of transfer records. A nil *Ledger is valid
+// and discards writes, which keeps persistence optional for callers.
+type Ledger struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Ledger, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open ledger %s: %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(transfersBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(handoffBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot initialize ledger %s: %w", path, err)
+	}
+
+	return &Ledger{db: db}, nil
+}
+
+// OpenReadOnly opens the ledger at path for reading only, for one-off
+// commands. It returns a nil ledger, which holds no records, when the file does
+// not exist yet, and fails fast while another process such as the daemon has
+// the ledger open for writing.
+func OpenReadOnly(path string) (*Ledger, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: readOnlyTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("ledger %s is in use, stop the daemon first: %w", path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open ledger %s: %w", path, err)
+	}
+	return &Ledger{db: db}, nil
+}
+
+// Handoff is written on shutdown and lists the transfers that had not reached
+// a terminal status, for the next run or an operator to pick up.
+type Handoff struct {
+	WrittenAt time.Time        `json:"written_at"`
+	Transfers []TransferRecord `json:"transfers"`
+}
+
+// WriteHandoff replaces the stored handoff with one listing every
+// non-terminal transfer.
+func (l *Ledger) WriteHandoff() (*Handoff, error) {
+	if l == nil {
+		return &Handoff{}, nil
+	}
+
+	records, err := l.NonTerminal()
+	if err != nil {
+		return nil, err
+	}
+	handoff := &Handoff{WrittenAt: time.Now().UTC(), Transfers: records}
+
+	data, err := json.Marshal(handoff)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode handoff: %w", err)
+	}
+	if err := l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(handoffBucket).Put(handoffKey, data)
+	}); err != nil {
+		return nil, err
+	}
+	return handoff, nil
+}
+
+// ReadHandoff returns the handoff written by the last shutdown, or nil if
+// there is none.
+func (l *Ledger) ReadHandoff() (*Handoff, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	var handoff *Handoff
+	err := l.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(handoffBucket).Get(handoffKey)
+		if data == nil {
+			return nil
+		}
+		handoff = &Handoff{}
+		if err := json.Unmarshal(data, handoff); err != nil {
+			return fmt.Errorf("cannot decode handoff: %w", err)
+		}
+		return nil
+	})
+	return handoff, err
+}
+
+func (l *Ledger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+// Create stores a new record. It fails if a record with the same idempotency
+// key already exists.
+func (l *Ledger) Create(record *TransferRecord) error {
+	if l == nil {
+		return nil
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	if len(record.Transitions) == 0 {
+		record.Transitions = []StatusTransition{{Status: record.Status, At: record.CreatedAt}}
+	}
+	record.UpdatedAt = record.CreatedAt
+
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(transfersBucket)
+		if bucket.Get([]byte(record.IdempotencyKey)) != nil {
+			return fmt.Errorf("transfer %s already recorded", record.IdempotencyKey)
+		}
+		return putRecord(bucket, record)
+	})
+}
+
+// Update applies fn to the stored record and persists the result.
+func (l *Ledger) Update(idempotencyKey string, fn func(record *TransferRecord)) error {
+	if l == nil {
+		return nil
+	}
+
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(transfersBucket)
+		record, err := getRecord(bucket, idempotencyKey)
+		if err != nil {
+			return err
+		}
+		fn(record)
+		return putRecord(bucket, record)
+	})
+}
+
+func (l *Ledger) UpdateStatus(idempotencyKey, status string) error {
+	return l.Update(idempotencyKey, func(record *TransferRecord) {
+		record.SetStatus(status)
+	})
+}
+
+func (l *Ledger) Get(idempotencyKey string) (*TransferRecord, error) {
+	if l == nil {
+		return nil, ErrTransferNotFound
+	}
+
+	var record *TransferRecord
+	err := l.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getRecord(tx.Bucket(transfersBucket), idempotencyKey)
+		return err
+	})
+	return record, err
+}
+
+// List returns every record ordered by creation time.
+func (l *Ledger) List() ([]TransferRecord, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	var records []TransferRecord
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(transfersBucket).ForEach(func(_, value []byte) error {
+			var record TransferRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records, nil
+}
+
+// NonTerminal returns every record that has not reached a terminal status.
+func (l *Ledger) NonTerminal() ([]TransferRecord, error) {
+	records, err := l.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []TransferRecord
+	for _, record := range records {
+		if !record.IsTerminal() {
+			pending = append(pending, record)
+		}
+	}
+	return pending, nil
+}
+
+// Dependents returns the records queued behind the record with the given
+// idempotency key.
+func (l *Ledger) Dependents(idempotencyKey string) ([]TransferRecord, error) {
+	records, err := l.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var dependents []TransferRecord
+	for _, record := range records {
+		if record.DependsOn == idempotencyKey {
+			dependents = append(dependents, record)
+		}
+	}
+	return dependents, nil
+}
+
+func getRecord(bucket *bolt.Bucket, idempotencyKey string) (*TransferRecord, error) {
+	value := bucket.Get([]byte(idempotencyKey))
+	if value == nil {
+		return nil, ErrTransferNotFound
+	}
+
+	record := &TransferRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return nil, fmt.Errorf("cannot decode transfer %s: %w", idempotencyKey, err)
+	}
+	return record, nil
+}
+
+func putRecord(bucket *bolt.Bucket, record *TransferRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(record.IdempotencyKey), value)
+}
@@ -0,0 +1,256 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const adminTestConfig = `
+rules:
+  - name: "hot_sweep"
+    direction: "trading_to_cold_custody"
+    schedule: "0 0 20 * * 1-5"
+    wallets:
+      - "ETH_cold"
+wallets:
+  - name: "ETH_cold"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-vault"
+daemon:
+  context_timeout_duration: 1
+  ledger_path: "%s"
+`
+
+func newTestAgent(t *testing.T) (*agent.SweeperAgent, *fake.PrimeClient, string) {
+	return newTestAgentWithConfig(t, adminTestConfig)
+}
+
+// newTestAgentWithConfig sets up an agent from configTemplate, whose only
+// verb is the ledger path.
+func newTestAgentWithConfig(t *testing.T, configTemplate string) (*agent.SweeperAgent, *fake.PrimeClient, string) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(configTemplate, filepath.Join(dir, "ledger.db"))
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sweeperAgent.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	return sweeperAgent, client, configPath
+}
+
+// eventRecorder is a notifier handing every event to a channel.
+type eventRecorder chan notify.Event
+
+func (r eventRecorder) Notify(_ context.Context, event notify.Event) error {
+	r <- event
+	return nil
+}
+
+// awaitEvent returns the first event of eventType, skipping the others.
+func awaitEvent(t *testing.T, events eventRecorder, eventType string) notify.Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", eventType)
+		}
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	sweeperAgent, client, _ := newTestAgent(t)
+	server := httptest.NewServer(sweeperAgent.AdminHandler())
+	defer server.Close()
+
+	getRules := func() []map[string]interface{} {
+		response, err := http.Get(server.URL + "/rules")
+		assert.NoError(t, err)
+		defer response.Body.Close()
+
+		var rules []map[string]interface{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&rules))
+		return rules
+	}
+
+	t.Run("list rules", func(t *testing.T) {
+		rules := getRules()
+		assert.Len(t, rules, 1)
+		assert.Equal(t, "hot_sweep", rules[0]["name"])
+		assert.Equal(t, false, rules[0]["paused"])
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		response, err := http.Post(server.URL+"/rules/hot_sweep/pause", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, true, getRules()[0]["paused"])
+
+		response, err = http.Post(server.URL+"/rules/hot_sweep/resume", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, false, getRules()[0]["paused"])
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		response, err := http.Post(server.URL+"/rules/missing/trigger", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("trigger and list in-flight transfers", func(t *testing.T) {
+		// A separate agent keeps the triggered run from overlapping the
+		// other subtests.
+		sweeperAgent, client, _ := newTestAgent(t)
+		server := httptest.NewServer(sweeperAgent.AdminHandler())
+		defer server.Close()
+		client.SetTransactionStatus("TRANSACTION_PENDING")
+
+		events := make(eventRecorder, 16)
+		notify.SetNotifier(events)
+		t.Cleanup(func() { notify.SetNotifier(nil) })
+
+		response, err := http.Post(server.URL+"/rules/hot_sweep/trigger", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+		// The run records its transfers before it finishes.
+		awaitEvent(t, events, notify.EventRuleFinished)
+		assert.Len(t, client.Transfers(), 1)
+
+		response, err = http.Get(server.URL + "/transfers")
+		assert.NoError(t, err)
+		defer response.Body.Close()
+
+		var records []store.TransferRecord
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&records))
+		if assert.Len(t, records, 1) {
+			assert.NotEmpty(t, records[0].ApprovalUrl)
+		}
+	})
+
+	t.Run("trigger is rejected while the rule is running", func(t *testing.T) {
+		release := client.Hold("GetWalletBalance")
+
+		response, err := http.Post(server.URL+"/rules/hot_sweep/trigger", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+		assert.Eventually(t, func() bool {
+			return getRules()[0]["running"] == true
+		}, time.Second, 10*time.Millisecond)
+
+		response, err = http.Post(server.URL+"/rules/hot_sweep/trigger", "", nil)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+		release()
+		assert.Eventually(t, func() bool {
+			return getRules()[0]["running"] == false
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("effective config", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/config")
+		assert.NoError(t, err)
+		defer response.Body.Close()
+
+		var config map[string]interface{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&config))
+		assert.Contains(t, config, "rules")
+		assert.Contains(t, config, "daemon")
+	})
+}
+
+const redactedConfigTest = `
+portfolios:
+  - name: "main"
+    credentials:
+      type: "exec"
+      command: ["fetch-credentials", "--portfolio", "main"]
+rules:
+  - name: "hot_sweep"
+    direction: "trading_to_cold_custody"
+    schedule: "0 0 20 * * 1-5"
+    wallets:
+      - "ETH_cold"
+wallets:
+  - name: "ETH_cold"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-vault"
+notifications:
+  webhook_url: "https://hooks.example.com/approvals"
+  sinks:
+    - name: "ops"
+      type: "slack"
+      url: "https://hooks.slack.com/services/T0/B0/secret"
+daemon:
+  context_timeout_duration: 1
+  ledger_path: "%s"
+`
+
+func TestAdminConfigIsRedacted(t *testing.T) {
+	sweeperAgent, _, _ := newTestAgentWithConfig(t, redactedConfigTest)
+	t.Cleanup(func() { notify.SetNotifier(nil) })
+	server := httptest.NewServer(sweeperAgent.AdminHandler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/config")
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	for _, secret := range []string{"hooks.example.com", "hooks.slack.com", "fetch-credentials"} {
+		assert.NotContains(t, string(body), secret)
+	}
+
+	var config model.Config
+	assert.NoError(t, json.Unmarshal(body, &config))
+	assert.Equal(t, "[redacted]", config.Notifications.WebhookUrl)
+	if assert.Len(t, config.Notifications.Sinks, 1) {
+		assert.Equal(t, "ops", config.Notifications.Sinks[0].Name)
+		assert.Equal(t, "[redacted]", config.Notifications.Sinks[0].Url)
+	}
+	if assert.Len(t, config.Portfolios, 1) && assert.NotNil(t, config.Portfolios[0].Credentials) {
+		assert.Equal(t, "exec", config.Portfolios[0].Credentials.Type)
+		assert.Equal(t, []string{"[redacted]"}, config.Portfolios[0].Credentials.Command)
+	}
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func allocationAmounts(allocations []core.Allocation) map[string]string {
+	amounts := make(map[string]string)
+	for _, allocation := range allocations {
+		amounts[allocation.WalletId] = allocation.Amount.String()
+	}
+	return amounts
+}
+
+func TestAllocateWeighted(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  []string
+		amount   string
+		expected map[string]string
+	}{
+		{
+			name:     "equal weights by default",
+			weights:  []string{"", ""},
+			amount:   "3",
+			expected: map[string]string{"vault-0": "1.5", "vault-1": "1.5"},
+		},
+		{
+			name:     "proportional to weights",
+			weights:  []string{"3", "1"},
+			amount:   "2",
+			expected: map[string]string{"vault-0": "1.5", "vault-1": "0.5"},
+		},
+		{
+			name:     "rounding remainder goes to last wallet",
+			weights:  []string{"1", "1", "1"},
+			amount:   "1",
+			expected: map[string]string{"vault-0": "0.33333333", "vault-1": "0.33333333", "vault-2": "0.33333334"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var wallets []model.Wallet
+			for i, weight := range tc.weights {
+				wallets = append(wallets, model.Wallet{Name: "cold", WalletId: fmt.Sprintf("vault-%d", i), Weight: weight})
+			}
+
+			allocations, err := core.AllocateWeighted(wallets, decimal.RequireFromString(tc.amount))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, allocationAmounts(allocations))
+		})
+	}
+}
+
+func TestAllocateFillToCap(t *testing.T) {
+	wallets := []model.Wallet{
+		{Name: "first", WalletId: "vault-0", Cap: "10"},
+		{Name: "second", WalletId: "vault-1", Cap: "5"},
+	}
+
+	tests := []struct {
+		name     string
+		balances map[string]decimal.Decimal
+		amount   string
+		expected map[string]string
+	}{
+		{
+			name:     "first wallet has room",
+			balances: map[string]decimal.Decimal{"vault-0": decimal.NewFromInt(8)},
+			amount:   "1",
+			expected: map[string]string{"vault-0": "1"},
+		},
+		{
+			name:     "overflow into next wallet",
+			balances: map[string]decimal.Decimal{"vault-0": decimal.NewFromInt(8)},
+			amount:   "4",
+			expected: map[string]string{"vault-0": "2", "vault-1": "2"},
+		},
+		{
+			name:     "remainder stays unallocated when all wallets are full",
+			balances: map[string]decimal.Decimal{"vault-0": decimal.NewFromInt(10), "vault-1": decimal.NewFromInt(4)},
+			amount:   "3",
+			expected: map[string]string{"vault-1": "1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			allocations, err := core.AllocateFillToCap(wallets, tc.balances, decimal.RequireFromString(tc.amount))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, allocationAmounts(allocations))
+		})
+	}
+}
+
+func TestLeastBalanceWallet(t *testing.T) {
+	wallets := []model.Wallet{{WalletId: "vault-0"}, {WalletId: "vault-1"}, {WalletId: "vault-2"}}
+
+	assert.Equal(t, "vault-1", core.LeastBalanceWallet(wallets, map[string]decimal.Decimal{
+		"vault-0": decimal.NewFromInt(5),
+		"vault-1": decimal.NewFromInt(1),
+		"vault-2": decimal.NewFromInt(3),
+	}))
+	assert.Equal(t, "vault-0", core.LeastBalanceWallet(wallets, map[string]decimal.Decimal{}),
+		"ties go to the wallet listed first")
+}
+
+func TestChunkAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		maxAmount string
+		expected  []string
+		expectErr bool
+	}{
+		{name: "no maximum", amount: "5", expected: []string{"5"}},
+		{name: "below maximum", amount: "0.5", maxAmount: "1", expected: []string{"0.5"}},
+		{name: "exact multiple", amount: "3", maxAmount: "1.5", expected: []string{"1.5", "1.5"}},
+		{name: "remainder in last chunk", amount: "2.25", maxAmount: "1", expected: []string{"1", "1", "0.25"}},
+		{name: "invalid maximum", amount: "1", maxAmount: "abc", expectErr: true},
+		{name: "maximum below granularity", amount: "1", maxAmount: "0.000000001", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks, err := core.ChunkAmount(decimal.RequireFromString(tc.amount), tc.maxAmount)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			var amounts []string
+			for _, chunk := range chunks {
+				amounts = append(amounts, chunk.String())
+			}
+			assert.Equal(t, tc.expected, amounts)
+		})
+	}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  []string
+		balances []string
+		expected map[string]map[string]string
+	}{
+		{
+			name:     "equal weights by default",
+			weights:  []string{"", ""},
+			balances: []string{"10", "0"},
+			expected: map[string]map[string]string{"vault-0": {"vault-1": "5"}},
+		},
+		{
+			name:     "proportional to weights",
+			weights:  []string{"1", "3"},
+			balances: []string{"4", "4"},
+			expected: map[string]map[string]string{"vault-0": {"vault-1": "2"}},
+		},
+		{
+			name:     "surplus is spread over deficits in order",
+			weights:  []string{"1", "1", "1"},
+			balances: []string{"9", "0", "3"},
+			expected: map[string]map[string]string{"vault-0": {"vault-1": "4", "vault-2": "1"}},
+		},
+		{
+			name:     "balanced wallets need no transfers",
+			weights:  []string{"2", "1"},
+			balances: []string{"2", "1"},
+			expected: map[string]map[string]string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var wallets []model.Wallet
+			balances := make(map[string]decimal.Decimal)
+			for i, weight := range tc.weights {
+				walletId := fmt.Sprintf("vault-%d", i)
+				wallets = append(wallets, model.Wallet{Name: "cold", WalletId: walletId, Weight: weight})
+				balances[walletId] = decimal.RequireFromString(tc.balances[i])
+			}
+
+			moves, err := core.PlanRebalance(wallets, balances)
+			assert.NoError(t, err)
+
+			actual := make(map[string]map[string]string)
+			for source, allocations := range moves {
+				actual[source] = allocationAmounts(allocations)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAssetsAboveThreshold(t *testing.T) {
+	rule := model.Rule{
+		Thresholds: map[string]string{
+			"BTC": "1",
+			"ETH": "10",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		balances map[string]decimal.Decimal
+		expected []string
+	}{
+		{
+			name: "below all thresholds",
+			balances: map[string]decimal.Decimal{
+				"BTC": decimal.RequireFromString("0.5"),
+				"ETH": decimal.RequireFromString("9.99"),
+			},
+			expected: nil,
+		},
+		{
+			name: "at threshold",
+			balances: map[string]decimal.Decimal{
+				"BTC": decimal.RequireFromString("1"),
+			},
+			expected: []string{"BTC"},
+		},
+		{
+			name: "above multiple thresholds",
+			balances: map[string]decimal.Decimal{
+				"BTC": decimal.RequireFromString("2"),
+				"ETH": decimal.RequireFromString("11"),
+				"SOL": decimal.RequireFromString("100"),
+			},
+			expected: []string{"BTC", "ETH"},
+		},
+		{
+			name:     "no balances",
+			balances: map[string]decimal.Decimal{},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := core.AssetsAboveThreshold(rule, tc.balances)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+const thresholdTestConfig = `
+rules:
+  - name: "threshold_sweep"
+    direction: "trading_to_cold_custody"
+    thresholds:
+      ETH: "1"
+      BTC: "5"
+    wallets:
+      - "ETH_cold"
+      - "BTC_cold"
+wallets:
+  - name: "ETH_cold"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-vault"
+  - name: "BTC_cold"
+    asset: "BTC"
+    type: "cold_custody"
+    wallet_id: "btc-vault"
+daemon:
+  context_timeout_duration: 1
+  threshold_poll_frequency: 1
+  ledger_path: "%s"
+`
+
+func TestThresholdSweepsOnlyCrossedAssets(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(thresholdTestConfig, filepath.Join(dir, "ledger.db"))
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("btc-trading", "TRADING", "BTC", "3")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+	client.AddWallet("btc-vault", "VAULT", "BTC", "0")
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(client))
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+
+	stopChan := make(chan os.Signal, 1)
+	stopped := make(chan error, 1)
+	go func() { stopped <- sweeperAgent.Run(stopChan) }()
+
+	assert.Eventually(t, func() bool {
+		return len(client.Transfers()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stopChan <- syscall.SIGTERM
+	assert.NoError(t, <-stopped)
+
+	transfers := client.Transfers()
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, "ETH", transfers[0].Symbol)
+	assert.Equal(t, "3", client.Balance("btc-trading").String(), "BTC is below its threshold and must not be swept")
+}
+
+// failingBalanceClient fails one GetWalletBalance call, counted from 1.
+type failingBalanceClient struct {
+	*fake.PrimeClient
+
+	mu       sync.Mutex
+	calls    int
+	failCall int
+}
+
+func (c *failingBalanceClient) GetWalletBalance(
+	ctx context.Context,
+	request *prime.GetWalletBalanceRequest,
+) (*prime.GetWalletBalanceResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	fail := c.calls == c.failCall
+	c.mu.Unlock()
+
+	if fail {
+		return nil, errors.New("prime unavailable")
+	}
+	return c.PrimeClient.GetWalletBalance(ctx, request)
+}
+
+func TestThresholdRetriesFailedRuns(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(thresholdTestConfig, filepath.Join(dir, "ledger.db"))
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("btc-trading", "TRADING", "BTC", "3")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+	client.AddWallet("btc-vault", "VAULT", "BTC", "0")
+
+	// The first tick reads both trading balances for the thresholds, then the
+	// run it starts fails to read the ETH balance.
+	failing := &failingBalanceClient{PrimeClient: client, failCall: 3}
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(failing))
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+
+	stopChan := make(chan os.Signal, 1)
+	stopped := make(chan error, 1)
+	go func() { stopped <- sweeperAgent.Run(stopChan) }()
+
+	assert.Eventually(t, func() bool {
+		return len(client.Transfers()) == 1
+	}, 5*time.Second, 10*time.Millisecond, "the failed run must be retried on the next tick")
+
+	stopChan <- syscall.SIGTERM
+	assert.NoError(t, <-stopped)
+
+	transfers := client.Transfers()
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, "ETH", transfers[0].Symbol)
+}
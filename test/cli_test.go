@@ -0,0 +1,87 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/coinbase-samples/prime-sweeper-go/cli"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCLI(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	validConfig := filepath.Join(filepath.Dir(filename), "test_config.yaml")
+	dir := t.TempDir()
+	invalidConfig := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalidConfig, []byte("rules: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "help", args: []string{"help"}, expected: 0},
+		{name: "command help", args: []string{"validate", "-h"}, expected: 0},
+		{name: "unknown command", args: []string{"sweep"}, expected: 2},
+		{name: "unknown flag", args: []string{"validate", "-verbose"}, expected: 2},
+		{name: "invalid log level", args: []string{"validate", "-log-level", "loud"}, expected: 2},
+		{name: "run-rule without a rule", args: []string{"run-rule", "-config", invalidConfig}, expected: 2},
+		{name: "missing config", args: []string{"validate", "-config", filepath.Join(dir, "missing.yaml")}, expected: 1},
+		{name: "invalid config", args: []string{"validate", "-config", invalidConfig}, expected: 1},
+		{name: "offline validation needs no credentials", args: []string{"validate", "-config", validConfig}, expected: 0},
+		{name: "unknown credentials source", args: []string{"validate", "-online", "-credentials", "vault", "-config", validConfig}, expected: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, cli.Main(tc.args))
+		})
+	}
+}
+
+// failingWriter fails every write, like a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestWriteWalletsCsv(t *testing.T) {
+	wallets := []cli.WalletRow{
+		{Name: "BTC Vault", Id: "btc-vault", Symbol: "BTC", Balance: "1.5"},
+		{Name: "ETH Vault", Id: "eth-vault", Symbol: "ETH", Balance: "0"},
+	}
+
+	tests := []struct {
+		name     string
+		wallets  []cli.WalletRow
+		expected string
+	}{
+		{
+			name:     "header only",
+			expected: "Name,ID,Symbol,Balance\n",
+		},
+		{
+			name:     "one line per wallet",
+			wallets:  wallets,
+			expected: "Name,ID,Symbol,Balance\nBTC Vault,btc-vault,BTC,1.5\nETH Vault,eth-vault,ETH,0\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var output bytes.Buffer
+			assert.NoError(t, cli.WriteWalletsCsv(&output, tc.wallets))
+			assert.Equal(t, tc.expected, output.String())
+		})
+	}
+
+	t.Run("write errors are returned", func(t *testing.T) {
+		assert.ErrorContains(t, cli.WriteWalletsCsv(failingWriter{}, wallets), "no space left on device")
+	})
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/credentials"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const credentialsJSON = `{"accessKey":"access","passphrase":"pass","signingKey":"signing","portfolioId":"portfolio-1"}`
+
+func TestFileCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    os.FileMode
+		content string
+		wantErr bool
+	}{
+		{name: "owner only", mode: 0600, content: credentialsJSON},
+		{name: "read only", mode: 0400, content: credentialsJSON},
+		{name: "group readable", mode: 0640, content: credentialsJSON, wantErr: true},
+		{name: "world readable", mode: 0644, content: credentialsJSON, wantErr: true},
+		{name: "missing keys", mode: 0600, content: `{"accessKey":"access"}`, wantErr: true},
+		{name: "invalid json", mode: 0600, content: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			creds, err := credentials.File(path).Credentials(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "portfolio-1", creds.PortfolioId)
+		})
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		wantErr bool
+	}{
+		{name: "prints credentials", command: []string{"sh", "-c", fmt.Sprintf("echo '%s'", credentialsJSON)}},
+		{name: "fails", command: []string{"sh", "-c", "echo denied >&2; exit 1"}, wantErr: true},
+		{name: "prints garbage", command: []string{"sh", "-c", "echo nope"}, wantErr: true},
+		{name: "no command", command: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := credentials.Exec(tt.command).Credentials(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "access", creds.AccessKey)
+		})
+	}
+}
+
+func TestVaultCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/sweeper":
+			fmt.Fprintf(w, `{"data":%s}`, credentialsJSON)
+		case "/v1/secret/data/sweeper":
+			fmt.Fprintf(w, `{"data":{"data":%s,"metadata":{"version":3}}}`, credentialsJSON)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		token   string
+		wantErr bool
+	}{
+		{name: "kv version 1", path: "secret/sweeper", token: "vault-token"},
+		{name: "kv version 2", path: "secret/data/sweeper", token: "vault-token"},
+		{name: "wrong token", path: "secret/sweeper", token: "other", wantErr: true},
+		{name: "no token", path: "secret/sweeper", wantErr: true},
+		{name: "unknown path", path: "secret/missing", token: "vault-token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_VAULT_TOKEN", tt.token)
+
+			creds, err := credentials.Vault(server.URL, tt.path, "TEST_VAULT_TOKEN").Credentials(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "portfolio-1", creds.PortfolioId)
+		})
+	}
+}
+
+func TestCachedCredentials(t *testing.T) {
+	var calls int
+	var current *prime.Credentials
+	var failing error
+	provider := credentials.ProviderFunc(func(context.Context) (*prime.Credentials, error) {
+		calls++
+		if failing != nil {
+			return nil, failing
+		}
+		return current, nil
+	})
+
+	ctx := context.Background()
+	current = &prime.Credentials{AccessKey: "first", PortfolioId: "portfolio-1"}
+
+	t.Run("serves from cache until the refresh interval passes", func(t *testing.T) {
+		cached := credentials.Cached(provider, time.Hour)
+		for i := 0; i < 3; i++ {
+			creds, err := cached.Credentials(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, "first", creds.AccessKey)
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("picks up rotated credentials and keeps them on failure", func(t *testing.T) {
+		cached := credentials.Cached(provider, time.Millisecond)
+		creds, err := cached.Credentials(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", creds.AccessKey)
+
+		current = &prime.Credentials{AccessKey: "second", PortfolioId: "portfolio-1"}
+		time.Sleep(5 * time.Millisecond)
+		creds, err = cached.Credentials(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", creds.AccessKey)
+
+		failing = errors.New("source unavailable")
+		time.Sleep(5 * time.Millisecond)
+		creds, err = cached.Credentials(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", creds.AccessKey)
+	})
+
+	t.Run("fails when nothing was ever loaded", func(t *testing.T) {
+		_, err := credentials.Cached(provider, time.Hour).Credentials(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestRotatingClient(t *testing.T) {
+	current := &prime.Credentials{AccessKey: "first", SigningKey: "signing", PortfolioId: "portfolio-1"}
+	provider := credentials.ProviderFunc(func(context.Context) (*prime.Credentials, error) {
+		return current, nil
+	})
+
+	client, err := utils.NewRotatingClient(context.Background(), provider)
+	assert.NoError(t, err)
+	assert.Equal(t, "portfolio-1", client.PortfolioId())
+
+	current = &prime.Credentials{AccessKey: "second", SigningKey: "signing", PortfolioId: "portfolio-2"}
+	_, err = client.GetWallet(context.Background(), &prime.GetWalletRequest{PortfolioId: "portfolio-1", Id: "wallet"})
+	assert.ErrorContains(t, err, "portfolio-2")
+	assert.Equal(t, "portfolio-1", client.PortfolioId())
+}
+
+func TestCredentialsFromConfig(t *testing.T) {
+	t.Setenv("TEST_PRIME_CREDENTIALS", credentialsJSON)
+
+	tests := []struct {
+		name      string
+		portfolio model.Portfolio
+		wantErr   bool
+	}{
+		{name: "credentials_env", portfolio: model.Portfolio{Name: "main", CredentialsEnv: "TEST_PRIME_CREDENTIALS"}},
+		{name: "env source", portfolio: model.Portfolio{Name: "main", Credentials: &model.CredentialsSource{Type: "env", Env: "TEST_PRIME_CREDENTIALS"}}},
+		{name: "unknown type", portfolio: model.Portfolio{Name: "main", Credentials: &model.CredentialsSource{Type: "keychain"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := credentials.FromConfig(tt.portfolio)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			creds, err := provider.Credentials(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "portfolio-1", creds.PortfolioId)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	scheduledAt := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+	key := core.IdempotencyKey("daily_hot_sweep", scheduledAt, "wallet1", "ETH")
+
+	_, err := uuid.Parse(key)
+	assert.NoError(t, err, "idempotency key should be a UUID")
+
+	assert.Equal(t, key, core.IdempotencyKey("daily_hot_sweep", scheduledAt.In(time.FixedZone("EST", -5*3600)), "wallet1", "ETH"),
+		"the same instant in another time zone should yield the same key")
+
+	tests := []struct {
+		name           string
+		ruleName       string
+		scheduledAt    time.Time
+		sourceWalletId string
+		symbol         string
+	}{
+		{"different rule", "other_rule", scheduledAt, "wallet1", "ETH"},
+		{"different fire time", "daily_hot_sweep", scheduledAt.Add(time.Second), "wallet1", "ETH"},
+		{"different source wallet", "daily_hot_sweep", scheduledAt, "wallet2", "ETH"},
+		{"different symbol", "daily_hot_sweep", scheduledAt, "wallet1", "BTC"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NotEqual(t, key, core.IdempotencyKey(tc.ruleName, tc.scheduledAt, tc.sourceWalletId, tc.symbol))
+		})
+	}
+}
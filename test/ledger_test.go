@@ -0,0 +1,40 @@
+package test
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+
+	ledger, err := store.Open(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ledger.Create(&store.TransferRecord{IdempotencyKey: "done", Status: store.StatusPending}))
+	assert.NoError(t, ledger.Create(&store.TransferRecord{IdempotencyKey: "pending", Status: store.StatusPending}))
+	assert.Error(t, ledger.Create(&store.TransferRecord{IdempotencyKey: "done", Status: store.StatusPending}),
+		"duplicate idempotency keys should be rejected")
+
+	assert.NoError(t, ledger.UpdateStatus("done", store.StatusSubmitted))
+	assert.NoError(t, ledger.UpdateStatus("done", "TRANSACTION_DONE"))
+	assert.ErrorIs(t, ledger.UpdateStatus("missing", store.StatusSubmitted), store.ErrTransferNotFound)
+	assert.NoError(t, ledger.Close())
+
+	ledger, err = store.Open(path)
+	assert.NoError(t, err)
+	defer ledger.Close()
+
+	record, err := ledger.Get("done")
+	assert.NoError(t, err)
+	assert.Equal(t, "TRANSACTION_DONE", record.Status)
+	assert.Len(t, record.Transitions, 3)
+	assert.True(t, record.IsTerminal())
+
+	pending, err := ledger.NonTerminal()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "pending", pending[0].IdempotencyKey)
+}
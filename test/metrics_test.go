@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/metrics"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	fakeClient, ledger, config := newProcessTransfersFixture(t)
+	client := metrics.InstrumentClient(fakeClient)
+	rule := model.Rule{
+		Name:      "metrics_hot_sweep",
+		Direction: string(model.HotToCold),
+		Wallets:   []string{"ETH_cold"},
+	}
+
+	portfolio, err := core.NewPortfolio("default", client, config)
+	assert.NoError(t, err)
+
+	executions := testutil.ToFloat64(metrics.RuleExecutions.WithLabelValues(rule.Name))
+	initiated := testutil.ToFloat64(metrics.TransfersInitiated.WithLabelValues(rule.Name, "ETH"))
+	swept := testutil.ToFloat64(metrics.SweptAmount.WithLabelValues(rule.Name, "ETH"))
+	balanceErrors := testutil.ToFloat64(metrics.PrimeRequestErrors.WithLabelValues("GetWalletBalance"))
+
+	core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+		Direction:   model.HotToCold,
+		WalletNames: rule.Wallets,
+		OperationId: "op",
+		RuleName:    rule.Name,
+		ScheduledAt: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, executions+1, testutil.ToFloat64(metrics.RuleExecutions.WithLabelValues(rule.Name)))
+	assert.Equal(t, initiated+1, testutil.ToFloat64(metrics.TransfersInitiated.WithLabelValues(rule.Name, "ETH")))
+	assert.Equal(t, swept+1.5, testutil.ToFloat64(metrics.SweptAmount.WithLabelValues(rule.Name, "ETH")))
+	assert.Equal(t, 1.5, testutil.ToFloat64(metrics.ObservedBalance.WithLabelValues("ETH", "eth-trading")))
+
+	fakeClient.FailWith("GetWalletBalance", errors.New("unavailable"))
+	core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+		Direction:   model.HotToCold,
+		WalletNames: rule.Wallets,
+		OperationId: "op",
+		RuleName:    rule.Name,
+		ScheduledAt: time.Date(2024, 1, 2, 20, 1, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, executions+2, testutil.ToFloat64(metrics.RuleExecutions.WithLabelValues(rule.Name)))
+	assert.Equal(t, balanceErrors+1, testutil.ToFloat64(metrics.PrimeRequestErrors.WithLabelValues("GetWalletBalance")))
+
+	fakeClient.FailWith("GetWalletBalance", nil)
+	notifications := make(eventRecorder, 16)
+	notify.SetNotifier(notifications)
+	t.Cleanup(func() { notify.SetNotifier(nil) })
+
+	core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+		Direction:   model.HotToCold,
+		WalletNames: rule.Wallets,
+		OperationId: "dry",
+		RuleName:    rule.Name,
+		ScheduledAt: time.Date(2024, 1, 2, 20, 2, 0, 0, time.UTC),
+		DryRun:      true,
+	})
+	assert.NoError(t, notify.Flush(context.Background()))
+
+	assert.Equal(t, executions+2, testutil.ToFloat64(metrics.RuleExecutions.WithLabelValues(rule.Name)), "dry runs are not executions")
+	for len(notifications) > 0 {
+		event := <-notifications
+		assert.NotEqual(t, notify.EventRuleStarted, event.Type, "dry runs are not announced")
+		assert.NotEqual(t, notify.EventRuleFinished, event.Type, "dry runs are not announced")
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.True(t, strings.Contains(recorder.Body.String(), "sweeper_transfers_initiated_total"))
+}
@@ -0,0 +1,267 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApprovalNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var events []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// The legacy webhook_url only receives approval events.
+	notifier, err := notify.FromConfig(model.NotificationConfig{WebhookUrl: server.URL})
+	assert.NoError(t, err)
+	notify.SetNotifier(notifier)
+	t.Cleanup(func() { notify.SetNotifier(nil) })
+
+	ruleEvents := func(ruleName string) []notify.Event {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var matching []notify.Event
+		for _, event := range events {
+			if event.RuleName == ruleName {
+				matching = append(matching, event)
+			}
+		}
+		return matching
+	}
+	eventTypes := func(ruleName string) []string {
+		var types []string
+		for _, event := range ruleEvents(ruleName) {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	sweep := func(t *testing.T, ruleName, status string) (*fake.PrimeClient, *store.Ledger) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetTransactionStatus(status)
+		config.Daemon.TransferMonitorFrequency = 1
+		config.Daemon.TransferMonitorTimeoutDuration = 1
+		config.Notifications.ApprovalReminderFrequency = 1
+		rule := model.Rule{
+			Name:      ruleName,
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC),
+		})
+		return client, ledger
+	}
+
+	t.Run("transfers awaiting approval are announced and reminded", func(t *testing.T) {
+		submittedAt := time.Now()
+		client, ledger := sweep(t, "approval_sweep", "TRANSACTION_REQUESTED")
+
+		// The first poll happens right after submission, well before the
+		// one second poll interval.
+		assert.Eventually(t, func() bool {
+			return len(eventTypes("approval_sweep")) == 1
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		first := ruleEvents("approval_sweep")[0]
+		assert.Equal(t, notify.EventApprovalPending, first.Type)
+		assert.Equal(t, "ETH", first.Symbol)
+		assert.Equal(t, "1.5", first.Amount)
+		assert.Equal(t, "op", first.OperationId)
+		assert.NotEmpty(t, first.ApprovalUrl)
+		assert.Equal(t, "TRANSACTION_REQUESTED", first.Status)
+		if assert.NotNil(t, first.PendingSince) {
+			assert.WithinDuration(t, submittedAt, *first.PendingSince, time.Second, "pending since the submission")
+		}
+
+		assert.Eventually(t, func() bool {
+			types := eventTypes("approval_sweep")
+			return len(types) >= 2 && types[1] == notify.EventApprovalReminder
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.NotContains(t, eventTypes("approval_sweep")[1:], notify.EventApprovalPending)
+
+		transfers := client.Transfers()
+		record, err := ledger.Get(transfers[0].IdempotencyKey)
+		assert.NoError(t, err)
+		client.UpdateTransactionStatus(record.TransactionId, "TRANSACTION_DONE")
+		assert.Eventually(t, func() bool {
+			stored, err := ledger.Get(record.IdempotencyKey)
+			return err == nil && stored.Status == "TRANSACTION_DONE"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		sent := len(eventTypes("approval_sweep"))
+		time.Sleep(1100 * time.Millisecond)
+		assert.Len(t, eventTypes("approval_sweep"), sent, "no reminders once the transfer left pending approval")
+	})
+
+	t.Run("transfers that need no approval are not announced", func(t *testing.T) {
+		client, ledger := sweep(t, "direct_sweep", "TRANSACTION_DONE")
+
+		transfers := client.Transfers()
+		if !assert.Len(t, transfers, 1) {
+			return
+		}
+		assert.Eventually(t, func() bool {
+			stored, err := ledger.Get(transfers[0].IdempotencyKey)
+			return err == nil && stored.Status == "TRANSACTION_DONE"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, notify.Flush(ctx))
+		assert.Empty(t, eventTypes("direct_sweep"), "the approval URL alone does not mean approval is needed")
+	})
+}
+
+func TestNotificationRouting(t *testing.T) {
+	type delivery struct {
+		body      []byte
+		timestamp string
+		signature string
+	}
+	var mu sync.Mutex
+	deliveries := make(map[string][]delivery)
+	collect := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			deliveries[name] = append(deliveries[name], delivery{
+				body:      body,
+				timestamp: r.Header.Get(notify.TimestampHeader),
+				signature: r.Header.Get(notify.SignatureHeader),
+			})
+			mu.Unlock()
+		}))
+	}
+	webhook := collect("webhook")
+	defer webhook.Close()
+	slack := collect("slack")
+	defer slack.Close()
+
+	t.Setenv("TEST_WEBHOOK_SECRET", "secret")
+	notifier, err := notify.FromConfig(model.NotificationConfig{
+		Sinks: []model.NotificationSink{
+			{Name: "webhook", Type: notify.SinkWebhook, Url: webhook.URL, SecretEnv: "TEST_WEBHOOK_SECRET",
+				MinSeverity: notify.SeverityWarning, Rules: []string{"hot_sweep"}},
+			{Name: "slack", Type: notify.SinkSlack, Url: slack.URL, Events: []string{notify.EventTransferCompleted}},
+		},
+	})
+	assert.NoError(t, err)
+
+	events := []notify.Event{
+		{Type: notify.EventRuleStarted, Severity: notify.SeverityInfo, RuleName: "hot_sweep"},
+		{Type: notify.EventRuleError, Severity: notify.SeverityError, RuleName: "hot_sweep", Error: "unavailable"},
+		{Type: notify.EventRuleError, Severity: notify.SeverityError, RuleName: "cold_sweep", Error: "unavailable"},
+		{Type: notify.EventTransferCompleted, Severity: notify.SeverityInfo, RuleName: "cold_sweep",
+			Symbol: "BTC", Amount: "1", SourceWalletId: "vault", DestinationWalletId: "trading"},
+	}
+	for _, event := range events {
+		assert.NoError(t, notifier.Notify(context.Background(), event))
+	}
+
+	t.Run("webhook filters by severity and rule and signs payloads", func(t *testing.T) {
+		assert.Len(t, deliveries["webhook"], 1)
+		received := deliveries["webhook"][0]
+
+		var event notify.Event
+		assert.NoError(t, json.Unmarshal(received.body, &event))
+		assert.Equal(t, notify.EventRuleError, event.Type)
+		assert.Equal(t, "hot_sweep", event.RuleName)
+		assert.NotContains(t, string(received.body), "pending_since", "only approval events carry it")
+
+		assert.NotEmpty(t, received.timestamp)
+		assert.Equal(t, notify.Sign("secret", received.timestamp, received.body), received.signature)
+	})
+
+	t.Run("slack filters by event and posts text", func(t *testing.T) {
+		assert.Len(t, deliveries["slack"], 1)
+
+		var payload map[string]string
+		assert.NoError(t, json.Unmarshal(deliveries["slack"][0].body, &payload))
+		assert.Contains(t, payload["text"], "transfer_completed")
+		assert.Contains(t, payload["text"], "1 BTC from vault to trading")
+	})
+
+	t.Run("missing webhook secret is rejected", func(t *testing.T) {
+		_, err := notify.FromConfig(model.NotificationConfig{
+			Sinks: []model.NotificationSink{{Name: "webhook", Type: notify.SinkWebhook, Url: webhook.URL, SecretEnv: "TEST_UNSET_SECRET"}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+// slowRecorder records the events it receives, taking longer for the first
+// one.
+type slowRecorder struct {
+	mu    sync.Mutex
+	types []string
+}
+
+func (r *slowRecorder) Notify(_ context.Context, event notify.Event) error {
+	r.mu.Lock()
+	first := len(r.types) == 0
+	r.mu.Unlock()
+	if first {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types = append(r.types, event.Type)
+	return nil
+}
+
+func TestNotificationsKeepTheirOrder(t *testing.T) {
+	recorder := &slowRecorder{}
+	notify.SetNotifier(recorder)
+	t.Cleanup(func() { notify.SetNotifier(nil) })
+
+	sent := []string{
+		notify.EventRuleStarted,
+		notify.EventTransferInitiated,
+		notify.EventApprovalPending,
+		notify.EventTransferCompleted,
+		notify.EventRuleFinished,
+	}
+	for _, eventType := range sent {
+		notify.Send(notify.Event{Type: eventType, RuleName: "hot_sweep"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, notify.Flush(ctx))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Equal(t, sent, recorder.types)
+}
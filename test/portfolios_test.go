@@ -0,0 +1,101 @@
+package test
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const portfoliosTestConfig = `
+portfolios:
+  - name: "main"
+    credentials_env: "MAIN_CREDENTIALS"
+  - name: "treasury"
+    credentials_env: "TREASURY_CREDENTIALS"
+rules:
+  - name: "main_sweep"
+    portfolio: "main"
+    direction: "trading_to_cold_custody"
+    schedule: "0 0 20 * * 1-5"
+    wallets:
+      - "main_ETH_cold"
+  - name: "treasury_sweep"
+    portfolio: "treasury"
+    direction: "trading_to_cold_custody"
+    schedule: "0 0 20 * * 1-5"
+    wallets:
+      - "%s"
+wallets:
+  - name: "main_ETH_cold"
+    portfolio: "main"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "main-eth-vault"
+  - name: "treasury_ETH_cold"
+    portfolio: "treasury"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "treasury-eth-vault"
+daemon:
+  context_timeout_duration: 1
+  ledger_path: "%s"
+`
+
+func TestPortfolios(t *testing.T) {
+	mainClient := fake.NewPrimeClient("main-portfolio")
+	mainClient.AddWallet("main-eth-trading", "TRADING", "ETH", "1")
+	mainClient.AddWallet("main-eth-vault", "VAULT", "ETH", "0")
+
+	treasury := fake.NewPrimeClient("treasury-portfolio")
+	treasury.AddWallet("treasury-eth-trading", "TRADING", "ETH", "5")
+	treasury.AddWallet("treasury-eth-vault", "VAULT", "ETH", "0")
+
+	clients := func(portfolio model.Portfolio) (utils.PrimeClient, error) {
+		switch portfolio.Name {
+		case "main":
+			return mainClient, nil
+		case "treasury":
+			return treasury, nil
+		}
+		return nil, fmt.Errorf("unknown portfolio %s", portfolio.Name)
+	}
+
+	writeConfig := func(t *testing.T, treasuryWallet string) string {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "config.yaml")
+		config := fmt.Sprintf(portfoliosTestConfig, treasuryWallet, filepath.Join(dir, "ledger.db"))
+		if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return configPath
+	}
+
+	t.Run("rules run against their own portfolio", func(t *testing.T) {
+		sweeperAgent, err := agent.NewSweeperAgent(writeConfig(t, "treasury_ETH_cold"), clients)
+		assert.NoError(t, err)
+		assert.NoError(t, sweeperAgent.Setup())
+
+		plan := sweeperAgent.Plan()
+		assert.Len(t, plan, 2)
+
+		amounts := make(map[string]string)
+		for _, planned := range plan {
+			amounts[planned.SourceWalletId+"->"+planned.DestinationWalletId] = planned.Amount
+		}
+		assert.Equal(t, map[string]string{
+			"main-eth-trading->main-eth-vault":         "1",
+			"treasury-eth-trading->treasury-eth-vault": "5",
+		}, amounts)
+	})
+
+	t.Run("rules cannot use wallets of another portfolio", func(t *testing.T) {
+		_, err := agent.NewSweeperAgent(writeConfig(t, "main_ETH_cold"), clients)
+		assert.ErrorContains(t, err, "belongs to portfolio 'main'")
+	})
+}
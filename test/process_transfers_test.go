@@ -0,0 +1,794 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newProcessTransfersFixture(t *testing.T) (*fake.PrimeClient, *store.Ledger, *model.Config) {
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "1.5")
+	client.AddWallet("btc-trading", "TRADING", "BTC", "0")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "10")
+	client.AddWallet("btc-vault", "VAULT", "BTC", "2")
+
+	config := &model.Config{
+		Daemon: model.DaemonConfig{
+			ContextTimeoutDuration: 1,
+		},
+		Wallets: []model.Wallet{
+			{Name: "ETH_cold", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault"},
+			{Name: "BTC_cold", Asset: "BTC", Type: "cold_custody", WalletId: "btc-vault"},
+		},
+	}
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+
+	// Each test tracks its transfers in its own tracker, so goroutines still
+	// polling the transfers of an earlier test never shadow this one's.
+	tracker := core.NewTracker()
+	core.SetTracker(tracker)
+	t.Cleanup(func() { _ = tracker.Stop(context.Background()) })
+
+	return client, ledger, config
+}
+
+func TestProcessTransfers(t *testing.T) {
+	scheduledAt := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+
+	t.Run("hot to cold sweeps non-empty trading balances", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		rule := model.Rule{
+			Name:      "hot_sweep",
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold", "BTC_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "eth-trading", transfers[0].SourceWalletId)
+		assert.Equal(t, "eth-vault", transfers[0].DestinationWalletId)
+		assert.Equal(t, "1.5", transfers[0].Amount)
+		assert.True(t, client.Balance("eth-trading").IsZero())
+
+		record, err := ledger.Get(transfers[0].IdempotencyKey)
+		assert.NoError(t, err)
+		assert.Equal(t, "hot_sweep", record.RuleName)
+		assert.Equal(t, "op", record.OperationId)
+		assert.NotEmpty(t, record.ActivityId)
+		assert.Equal(t, transfers[0], record.Request)
+	})
+
+	t.Run("hot to cold keeps retained balance behind", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("btc-trading", "4")
+		config.Wallets[1].RetainAmount = "3"
+		rule := model.Rule{
+			Name:             "hot_sweep",
+			Direction:        string(model.HotToCold),
+			Wallets:          []string{"ETH_cold", "BTC_cold"},
+			RetainPercentage: "100",
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "btc-trading", transfers[0].SourceWalletId)
+		assert.Equal(t, "1", transfers[0].Amount)
+		assert.Equal(t, "1.5", client.Balance("eth-trading").String())
+	})
+
+	t.Run("amounts below min sweep amount are skipped", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("btc-trading", "0.0001")
+		rule := model.Rule{
+			Name:           "hot_sweep",
+			Direction:      string(model.HotToCold),
+			Wallets:        []string{"ETH_cold", "BTC_cold"},
+			MinSweepAmount: "0.01",
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "eth-trading", transfers[0].SourceWalletId)
+		assert.Equal(t, "0.0001", client.Balance("btc-trading").String())
+	})
+
+	t.Run("hot to cold rotates through cold wallets", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.AddWallet("eth-vault-2", "VAULT", "ETH", "0")
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_cold_2", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault-2"})
+		rule := model.Rule{
+			Name:       "hot_sweep",
+			Direction:  string(model.HotToCold),
+			Wallets:    []string{"ETH_cold", "ETH_cold_2"},
+			Allocation: model.AllocationRoundRobin,
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			client.SetBalance("eth-trading", "1")
+			core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+				Direction:   model.HotToCold,
+				WalletNames: rule.Wallets,
+				OperationId: "op",
+				RuleName:    rule.Name,
+				ScheduledAt: scheduledAt.Add(time.Duration(i) * time.Minute),
+			})
+		}
+
+		var destinations []string
+		for _, transfer := range client.Transfers() {
+			destinations = append(destinations, transfer.DestinationWalletId)
+		}
+		assert.Equal(t, []string{"eth-vault", "eth-vault-2", "eth-vault"}, destinations)
+	})
+
+	t.Run("hot to cold splits by weight", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.AddWallet("eth-vault-2", "VAULT", "ETH", "0")
+		config.Wallets[0].Weight = "2"
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_cold_2", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault-2"})
+		rule := model.Rule{
+			Name:       "hot_sweep",
+			Direction:  string(model.HotToCold),
+			Wallets:    []string{"ETH_cold", "ETH_cold_2"},
+			Allocation: model.AllocationWeighted,
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		transferDetails := model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		}
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+		client.SetBalance("eth-trading", "1.5")
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 2, "re-executing the tick must not submit the parts again")
+		assert.Equal(t, "11", client.Balance("eth-vault").String())
+		assert.Equal(t, "0.5", client.Balance("eth-vault-2").String())
+		assert.NotEqual(t, transfers[0].IdempotencyKey, transfers[1].IdempotencyKey)
+	})
+
+	t.Run("large sweeps are submitted in sequential chunks", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("eth-trading", "2.5")
+		config.Daemon.TransferMonitorTimeoutDuration = 1
+		rule := model.Rule{
+			Name:              "hot_sweep",
+			Direction:         string(model.HotToCold),
+			Wallets:           []string{"ETH_cold"},
+			MaxTransferAmount: "1",
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(client.Transfers()) == 3
+		}, 5*time.Second, 10*time.Millisecond)
+
+		var amounts []string
+		for _, transfer := range client.Transfers() {
+			amounts = append(amounts, transfer.Amount)
+		}
+		assert.Equal(t, []string{"1", "1", "0.5"}, amounts)
+		assert.Equal(t, "12.5", client.Balance("eth-vault").String())
+	})
+
+	t.Run("weighted shares are each chunked with distinct keys", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("eth-trading", "3")
+		client.AddWallet("eth-vault-2", "VAULT", "ETH", "0")
+		config.Daemon.TransferMonitorTimeoutDuration = 1
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_cold_2", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault-2"})
+		rule := model.Rule{
+			Name:              "hot_sweep",
+			Direction:         string(model.HotToCold),
+			Wallets:           []string{"ETH_cold", "ETH_cold_2"},
+			Allocation:        model.AllocationWeighted,
+			MaxTransferAmount: "1",
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(client.Transfers()) == 4
+		}, 5*time.Second, 10*time.Millisecond)
+
+		keys := make(map[string]bool)
+		for _, transfer := range client.Transfers() {
+			keys[transfer.IdempotencyKey] = true
+		}
+		assert.Len(t, keys, 4)
+		assert.Equal(t, "11.5", client.Balance("eth-vault").String())
+		assert.Equal(t, "1.5", client.Balance("eth-vault-2").String())
+	})
+
+	t.Run("fill to cap counts funds on hold against the cap", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("eth-trading", "3")
+		client.SetBalance("eth-vault", "4")
+		client.SetHeld("eth-vault", "4")
+		client.AddWallet("eth-vault-2", "VAULT", "ETH", "0")
+		config.Wallets[0].Cap = "10"
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_cold_2", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault-2"})
+		rule := model.Rule{
+			Name:       "hot_sweep",
+			Direction:  string(model.HotToCold),
+			Wallets:    []string{"ETH_cold", "ETH_cold_2"},
+			Allocation: model.AllocationFillToCap,
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Equal(t, "6", client.Balance("eth-vault").String(), "the held 4 leave room for 2 under the cap of 10")
+		assert.Equal(t, "1", client.Balance("eth-vault-2").String())
+	})
+
+	t.Run("failed polls do not stall the sequence", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("eth-trading", "2")
+		client.FailNext("GetActivity", errors.New("expected status code: 200 - received: 503"), 2)
+		client.FailNext("GetTransaction", errors.New("expected status code: 200 - received: 503"), 2)
+		config.Daemon.TransferMonitorTimeoutDuration = 1
+		rule := model.Rule{
+			Name:              "hot_sweep",
+			Direction:         string(model.HotToCold),
+			Wallets:           []string{"ETH_cold"},
+			MaxTransferAmount: "1",
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Eventually(t, func() bool {
+			pending, err := ledger.NonTerminal()
+			return err == nil && len(pending) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Len(t, client.Transfers(), 2)
+		assert.Equal(t, "12", client.Balance("eth-vault").String())
+	})
+
+	for _, status := range []string{"TRANSACTION_REJECTED", "TRANSACTION_CANCELLED", "TRANSACTION_EXPIRED"} {
+		t.Run(status+" chunk cancels the rest of the sequence", func(t *testing.T) {
+			client, ledger, config := newProcessTransfersFixture(t)
+			client.SetBalance("eth-trading", "2.5")
+			client.SetTransactionStatus(status)
+			config.Daemon.TransferMonitorTimeoutDuration = 1
+			rule := model.Rule{
+				Name:              "hot_sweep",
+				Direction:         string(model.HotToCold),
+				Wallets:           []string{"ETH_cold"},
+				MaxTransferAmount: "1",
+			}
+
+			portfolio, err := core.NewPortfolio("default", client, config)
+			assert.NoError(t, err)
+
+			core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+				Direction:   model.HotToCold,
+				WalletNames: rule.Wallets,
+				OperationId: "op",
+				RuleName:    rule.Name,
+				ScheduledAt: scheduledAt,
+			})
+
+			assert.Eventually(t, func() bool {
+				pending, err := ledger.NonTerminal()
+				return err == nil && len(pending) == 0
+			}, 5*time.Second, 10*time.Millisecond)
+
+			assert.Len(t, client.Transfers(), 1)
+			records, err := ledger.List()
+			assert.NoError(t, err)
+			var statuses []string
+			for _, record := range records {
+				statuses = append(statuses, record.Status)
+			}
+			assert.ElementsMatch(t, []string{status, store.StatusCancelled, store.StatusCancelled}, statuses)
+		})
+	}
+
+	t.Run("transfers over a velocity limit are skipped", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		config.Limits = []model.VelocityLimit{{Asset: "ETH", Window: 86400, MaxAmount: "2"}}
+		rule := model.Rule{
+			Name:      "hot_sweep",
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			client.SetBalance("eth-trading", "1.5")
+			core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+				Direction:   model.HotToCold,
+				WalletNames: rule.Wallets,
+				OperationId: "op",
+				RuleName:    rule.Name,
+				ScheduledAt: scheduledAt.Add(time.Duration(i) * time.Minute),
+			})
+		}
+
+		assert.Len(t, client.Transfers(), 1)
+		assert.Equal(t, "1.5", client.Balance("eth-trading").String())
+	})
+
+	t.Run("cold to hot sweeps listed cold wallets", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		rule := model.Rule{
+			Name:      "cold_sweep",
+			Direction: string(model.ColdToHot),
+			Wallets:   []string{"BTC_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.ColdToHot,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "btc-vault", transfers[0].SourceWalletId)
+		assert.Equal(t, "btc-trading", transfers[0].DestinationWalletId)
+		assert.Equal(t, "2", client.Balance("btc-trading").String())
+	})
+
+	t.Run("cold to cold drains into destination wallets", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.AddWallet("eth-deep", "VAULT", "ETH", "0")
+		config.Wallets[0].RetainAmount = "4"
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_deep", Asset: "ETH", Type: "cold_custody", WalletId: "eth-deep"})
+		rule := model.Rule{
+			Name:               "drain",
+			Direction:          string(model.ColdToCold),
+			Wallets:            []string{"ETH_cold"},
+			DestinationWallets: []string{"ETH_deep"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.ColdToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "eth-vault", transfers[0].SourceWalletId)
+		assert.Equal(t, "eth-deep", transfers[0].DestinationWalletId)
+		assert.Equal(t, "4", client.Balance("eth-vault").String())
+		assert.Equal(t, "6", client.Balance("eth-deep").String())
+		assert.Equal(t, "1.5", client.Balance("eth-trading").String(), "trading wallets are not touched")
+	})
+
+	t.Run("cold to cold rebalances to weights", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.AddWallet("eth-vault-2", "VAULT", "ETH", "0")
+		config.Wallets[0].Weight = "1"
+		config.Wallets = append(config.Wallets, model.Wallet{Name: "ETH_cold_2", Asset: "ETH", Type: "cold_custody", WalletId: "eth-vault-2", Weight: "4"})
+		rule := model.Rule{
+			Name:      "rebalance",
+			Direction: string(model.ColdToCold),
+			Wallets:   []string{"ETH_cold", "ETH_cold_2"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		transferDetails := model.TransferDetails{
+			Direction:   model.ColdToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		}
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+
+		assert.Len(t, client.Transfers(), 1)
+		assert.Equal(t, "2", client.Balance("eth-vault").String())
+		assert.Equal(t, "8", client.Balance("eth-vault-2").String())
+
+		client.SetBalance("eth-vault", "7")
+		transferDetails.ScheduledAt = scheduledAt.Add(time.Hour)
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+		assert.Len(t, client.Transfers(), 1, "an asset with a rebalance in flight is skipped")
+	})
+
+	t.Run("cold to hot tops up to target balance", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("btc-trading", "0.25")
+		client.SetTransactionStatus("TRANSACTION_PENDING")
+		rule := model.Rule{
+			Name:           "cold_top_up",
+			Direction:      string(model.ColdToHot),
+			Wallets:        []string{"BTC_cold"},
+			TargetBalances: map[string]string{"BTC": "0.75"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		transferDetails := model.TransferDetails{
+			Direction:   model.ColdToHot,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		}
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "btc-vault", transfers[0].SourceWalletId)
+		assert.Equal(t, "0.5", transfers[0].Amount)
+
+		// The first top-up is still in flight, so the trading wallet is
+		// already considered funded.
+		client.SetBalance("btc-trading", "0.25")
+		transferDetails.ScheduledAt = scheduledAt.Add(time.Minute)
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+		assert.Len(t, client.Transfers(), 1)
+	})
+
+	t.Run("cold to hot does nothing above target balance", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("btc-trading", "1")
+		rule := model.Rule{
+			Name:           "cold_top_up",
+			Direction:      string(model.ColdToHot),
+			Wallets:        []string{"BTC_cold"},
+			TargetBalances: map[string]string{"BTC": "0.75"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.ColdToHot,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Empty(t, client.Transfers())
+		assert.Equal(t, "2", client.Balance("btc-vault").String())
+	})
+
+	t.Run("cold to hot counts funds on hold towards target balance", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("btc-trading", "0.25")
+		client.SetHeld("btc-trading", "0.25")
+		rule := model.Rule{
+			Name:           "cold_top_up",
+			Direction:      string(model.ColdToHot),
+			Wallets:        []string{"BTC_cold"},
+			TargetBalances: map[string]string{"BTC": "0.75"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.ColdToHot,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		if assert.Len(t, transfers, 1) {
+			assert.Equal(t, "0.25", transfers[0].Amount)
+		}
+	})
+
+	t.Run("dry run returns plan without submitting", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		client.SetBalance("eth-trading", "1.123456789")
+		rule := model.Rule{
+			Name:      "hot_sweep",
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		plan, err := core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+			DryRun:      true,
+		})
+
+		assert.Equal(t, []core.PlannedTransfer{
+			{
+				RuleName:            "hot_sweep",
+				OperationId:         "op",
+				SourceWalletId:      "eth-trading",
+				DestinationWalletId: "eth-vault",
+				Symbol:              "ETH",
+				Amount:              "1.12345678",
+				IdempotencyKey:      core.IdempotencyKey("hot_sweep", scheduledAt, "eth-trading", "ETH"),
+			},
+		}, plan)
+		assert.Empty(t, client.Transfers())
+		assert.Equal(t, "1.123456789", client.Balance("eth-trading").String())
+	})
+
+	t.Run("balance failure aborts the run", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		rule := model.Rule{
+			Name:      "hot_sweep",
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		client.FailWith("GetWalletBalance", errors.New("unavailable"))
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		assert.Empty(t, client.Transfers())
+	})
+
+	t.Run("wallets whose balance fails are skipped", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		config.Wallets = append(config.Wallets, model.Wallet{
+			Name: "SOL_cold", Asset: "SOL", Type: "cold_custody", WalletId: "sol-vault",
+		})
+		rule := model.Rule{
+			Name:      "cold_sweep",
+			Direction: string(model.ColdToHot),
+			Wallets:   []string{"SOL_cold", "ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		core.ProcessTransfers(portfolio, ledger, config, rule, model.TransferDetails{
+			Direction:   model.ColdToHot,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		})
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "eth-vault", transfers[0].SourceWalletId)
+	})
+
+	t.Run("re-executing a tick does not submit twice", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		rule := model.Rule{
+			Name:      "hot_sweep",
+			Direction: string(model.HotToCold),
+			Wallets:   []string{"ETH_cold"},
+		}
+
+		portfolio, err := core.NewPortfolio("default", client, config)
+		assert.NoError(t, err)
+
+		transferDetails := model.TransferDetails{
+			Direction:   model.HotToCold,
+			WalletNames: rule.Wallets,
+			OperationId: "op",
+			RuleName:    rule.Name,
+			ScheduledAt: scheduledAt,
+		}
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+		client.SetBalance("eth-trading", "3")
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, core.IdempotencyKey("hot_sweep", scheduledAt, "eth-trading", "ETH"), transfers[0].IdempotencyKey)
+
+		transferDetails.ScheduledAt = scheduledAt.Add(time.Minute)
+		core.ProcessTransfers(portfolio, ledger, config, rule, transferDetails)
+		assert.Len(t, client.Transfers(), 2)
+	})
+
+	t.Run("resume resubmits pending transfers", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		record := store.TransferRecord{
+			IdempotencyKey: "key",
+			OperationId:    "op",
+			RuleName:       "hot_sweep",
+			Request: prime.CreateWalletTransferRequest{
+				PortfolioId:         "portfolio",
+				SourceWalletId:      "eth-trading",
+				Symbol:              "ETH",
+				DestinationWalletId: "eth-vault",
+				IdempotencyKey:      "key",
+				Amount:              "1",
+			},
+			Status: store.StatusPending,
+		}
+		assert.NoError(t, ledger.Create(&record))
+
+		assert.NoError(t, core.ResumeTransfers(client, ledger, config))
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, "key", transfers[0].IdempotencyKey)
+
+		stored, err := ledger.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusSubmitted, stored.Status)
+	})
+
+	t.Run("transfers past the tracking age limit stop being tracked", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		config.Daemon.TransferMonitorFrequency = 60
+		request := prime.CreateWalletTransferRequest{
+			PortfolioId:         "portfolio",
+			SourceWalletId:      "eth-trading",
+			Symbol:              "ETH",
+			DestinationWalletId: "eth-vault",
+			Amount:              "1",
+		}
+		submitted := store.TransferRecord{
+			IdempotencyKey: "key",
+			OperationId:    "op",
+			RuleName:       "hot_sweep",
+			Request:        request,
+			TransactionId:  "transaction",
+			Status:         store.StatusSubmitted,
+			CreatedAt:      time.Now().Add(-8 * 24 * time.Hour),
+		}
+		queued := store.TransferRecord{
+			IdempotencyKey: "key-2",
+			OperationId:    "op",
+			RuleName:       "hot_sweep",
+			Request:        request,
+			Status:         store.StatusQueued,
+			DependsOn:      "key",
+		}
+		assert.NoError(t, ledger.Create(&submitted))
+		assert.NoError(t, ledger.Create(&queued))
+
+		assert.NoError(t, core.ResumeTransfers(client, ledger, config))
+
+		assert.Eventually(t, func() bool {
+			pending, err := ledger.NonTerminal()
+			return err == nil && len(pending) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := ledger.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusUntracked, stored.Status)
+		stored, err = ledger.Get("key-2")
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusCancelled, stored.Status)
+		assert.Empty(t, client.Transfers())
+	})
+}
+
+func TestCollectWalletBalances(t *testing.T) {
+	client, _, config := newProcessTransfersFixture(t)
+	client.AddWallet("sol-vault", "VAULT", "SOL", "0")
+
+	balances, err := core.CollectWalletBalances(client, config, []string{"eth-vault", "missing", "btc-vault", "sol-vault"})
+
+	var failed core.WalletErrors
+	assert.ErrorAs(t, err, &failed)
+	assert.Len(t, failed, 1)
+	assert.Contains(t, failed, "missing")
+
+	assert.Len(t, balances, 2)
+	assert.Equal(t, "10", balances["eth-vault"].WithdrawableAmount.String())
+	assert.Equal(t, "2", balances["btc-vault"].WithdrawableAmount.String())
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("burst is served immediately, then the rate applies", func(t *testing.T) {
+		limiter := utils.NewRateLimiter(20, 3)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, limiter.Wait(context.Background(), utils.PriorityNormal))
+		}
+		assert.Less(t, time.Since(start), 25*time.Millisecond)
+
+		assert.NoError(t, limiter.Wait(context.Background(), utils.PriorityNormal))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("higher priority waiters are served first", func(t *testing.T) {
+		limiter := utils.NewRateLimiter(20, 1)
+		assert.NoError(t, limiter.Wait(context.Background(), utils.PriorityNormal))
+
+		var mu sync.Mutex
+		var order []utils.Priority
+		var wg sync.WaitGroup
+		wait := func(priority utils.Priority) {
+			defer wg.Done()
+			assert.NoError(t, limiter.Wait(context.Background(), priority))
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+		}
+
+		wg.Add(3)
+		go wait(utils.PriorityLow)
+		go wait(utils.PriorityLow)
+		time.Sleep(10 * time.Millisecond)
+		go wait(utils.PriorityHigh)
+		wg.Wait()
+
+		assert.Equal(t, []utils.Priority{utils.PriorityHigh, utils.PriorityLow, utils.PriorityLow}, order)
+	})
+
+	t.Run("cancelled waiters give up their place", func(t *testing.T) {
+		limiter := utils.NewRateLimiter(10, 1)
+		assert.NoError(t, limiter.Wait(context.Background(), utils.PriorityNormal))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, limiter.Wait(ctx, utils.PriorityHigh), context.DeadlineExceeded)
+
+		start := time.Now()
+		assert.NoError(t, limiter.Wait(context.Background(), utils.PriorityLow))
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
 	"github.com/coinbase-samples/prime-sweeper-go/utils"
 	"github.com/stretchr/testify/assert"
@@ -10,6 +11,9 @@ import (
 )
 
 func TestReadConfig(t *testing.T) {
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("0ed06581-e121-4fe6-81df-1d5187432977", "VAULT", "ETH", "0")
+
 	t.Run("Success", func(t *testing.T) {
 		_, filename, _, _ := runtime.Caller(0)
 		dir := filepath.Dir(filename)
@@ -41,14 +45,14 @@ func TestReadConfig(t *testing.T) {
 		}
 
 		configFilePath := filepath.Join(dir, "test_config.yaml")
-		config, err := utils.ReadConfig(configFilePath)
+		config, err := utils.ReadConfig(configFilePath, utils.StaticClient(client))
 		assert.NoError(t, err, "config should be loaded without errors")
 		assert.Equal(t, expectedConfig, *config, "loaded config should match expected config")
 	})
 
 	t.Run("Failure", func(t *testing.T) {
 		invalidConfigFilePath := "/path/to/nonexistent/config.yaml"
-		_, err := utils.ReadConfig(invalidConfigFilePath)
+		_, err := utils.ReadConfig(invalidConfigFilePath, utils.StaticClient(client))
 		assert.Error(t, err, "an error was expected when attempting to read a non-existent or invalid config file")
 	})
 }
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const reloadedRules = `
+  - name: "cold_sweep"
+    direction: "cold_custody_to_trading"
+    schedule: "0 0 4 * * 1-5"
+    wallets:
+      - "ETH_cold"
+`
+
+func TestReload(t *testing.T) {
+	sweeperAgent, _, configPath := newTestAgent(t)
+	server := httptest.NewServer(sweeperAgent.AdminHandler())
+	defer server.Close()
+
+	original, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+
+	ruleNames := func() []string {
+		response, err := http.Get(server.URL + "/rules")
+		assert.NoError(t, err)
+		defer response.Body.Close()
+
+		var rules []struct {
+			Name string `json:"name"`
+		}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&rules))
+
+		var names []string
+		for _, rule := range rules {
+			names = append(names, rule.Name)
+		}
+		return names
+	}
+
+	t.Run("invalid config is rejected", func(t *testing.T) {
+		invalid := []byte("rules:\n  - name: \"broken\"\n    direction: \"trading_to_cold_custody\"\n    schedule: \"not a cron\"\n")
+		assert.NoError(t, os.WriteFile(configPath, invalid, 0600))
+
+		assert.Error(t, sweeperAgent.Reload())
+		assert.Equal(t, []string{"hot_sweep"}, ruleNames())
+	})
+
+	t.Run("rules are added", func(t *testing.T) {
+		content := strings.Replace(string(original), "rules:\n", "rules:\n"+reloadedRules[1:], 1)
+		assert.NoError(t, os.WriteFile(configPath, []byte(content), 0600))
+
+		assert.NoError(t, sweeperAgent.Reload())
+		assert.ElementsMatch(t, []string{"hot_sweep", "cold_sweep"}, ruleNames())
+	})
+}
+
+func TestReloadResolvesEachClientOnce(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(adminTestConfig, filepath.Join(dir, "ledger.db"))
+	assert.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+
+	var mu sync.Mutex
+	resolved := 0
+	clients := func(model.Portfolio) (utils.PrimeClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolved++
+		return client, nil
+	}
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, clients)
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+
+	mu.Lock()
+	resolved = 0
+	mu.Unlock()
+
+	assert.NoError(t, sweeperAgent.Reload())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, resolved, "validation and setup must share the client")
+}
+
+func TestReloadAppliesThresholdPollFrequency(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(thresholdTestConfig, filepath.Join(dir, "ledger.db"))
+	slow := strings.Replace(config, "threshold_poll_frequency: 1", "threshold_poll_frequency: 3600", 1)
+	assert.NoError(t, os.WriteFile(configPath, []byte(slow), 0600))
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("btc-trading", "TRADING", "BTC", "3")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+	client.AddWallet("btc-vault", "VAULT", "BTC", "0")
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(client))
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+
+	stopChan := make(chan os.Signal, 1)
+	stopped := make(chan error, 1)
+	go func() { stopped <- sweeperAgent.Run(stopChan) }()
+	// Lets the watcher start on the hour-long frequency first.
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+	stopChan <- syscall.SIGHUP
+
+	assert.Eventually(t, func() bool {
+		return len(client.Transfers()) == 1
+	}, 5*time.Second, 10*time.Millisecond, "the new poll frequency must apply without a restart")
+
+	stopChan <- syscall.SIGTERM
+	assert.NoError(t, <-stopped)
+}
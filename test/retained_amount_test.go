@@ -0,0 +1,62 @@
+package test
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRetainedAmount(t *testing.T) {
+	tests := []struct {
+		name             string
+		retainAmount     string
+		retainPercentage string
+		withdrawable     string
+		expected         string
+		expectErr        bool
+	}{
+		{
+			name:         "no retention",
+			withdrawable: "10",
+			expected:     "0",
+		},
+		{
+			name:         "fixed amount",
+			retainAmount: "2.5",
+			withdrawable: "10",
+			expected:     "2.5",
+		},
+		{
+			name:             "percentage",
+			retainPercentage: "25",
+			withdrawable:     "10",
+			expected:         "2.5",
+		},
+		{
+			name:             "larger of fixed and percentage",
+			retainAmount:     "1",
+			retainPercentage: "50",
+			withdrawable:     "10",
+			expected:         "5",
+		},
+		{
+			name:         "invalid amount",
+			retainAmount: "abc",
+			withdrawable: "10",
+			expectErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := core.RetainedAmount(tc.retainAmount, tc.retainPercentage, decimal.RequireFromString(tc.withdrawable))
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, decimal.RequireFromString(tc.expected).Equal(result), "expected %s, got %s", tc.expected, result)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func primeError(code int) error {
+	return fmt.Errorf("expected status code: 200 - received: %d - status msg: - url https://api.prime.coinbase.com - msg: ", code)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "too many requests", err: primeError(429), expected: true},
+		{name: "server error", err: primeError(500), expected: true},
+		{name: "bad gateway", err: primeError(502), expected: true},
+		{name: "bad request", err: primeError(400), expected: false},
+		{name: "unauthorized", err: primeError(401), expected: false},
+		{name: "not found", err: primeError(404), expected: false},
+		{name: "wrapped server error", err: fmt.Errorf("cannot list wallets: %w", primeError(503)), expected: true},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, expected: true},
+		{name: "attempt timeout", err: context.DeadlineExceeded, expected: true},
+		{name: "cancelled", err: context.Canceled, expected: false},
+		{name: "unknown", err: errors.New("insufficient balance"), expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, utils.IsRetryable(tc.err))
+		})
+	}
+}
+
+func TestRetryingClient(t *testing.T) {
+	scheduledAt := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+	rule := model.Rule{
+		Name:      "hot_sweep",
+		Direction: string(model.HotToCold),
+		Wallets:   []string{"ETH_cold", "BTC_cold"},
+	}
+	details := model.TransferDetails{
+		Direction:   model.HotToCold,
+		WalletNames: rule.Wallets,
+		OperationId: "op",
+		RuleName:    rule.Name,
+		ScheduledAt: scheduledAt,
+	}
+	policy := func(config *model.Config) utils.RetryPolicy {
+		config.Daemon.Retry = model.RetryConfig{MaxAttempts: 3, InitialBackoffMs: 1, MaxBackoffMs: 2}
+		return utils.GetRetryPolicy(config)
+	}
+
+	t.Run("transient failures are retried with the same idempotency key", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		portfolio, err := core.NewPortfolio("default", utils.NewRetryingClient(client, policy(config)), config)
+		assert.NoError(t, err)
+
+		client.FailNext("GetWalletBalance", primeError(503), 1)
+		client.FailNext("CreateWalletTransfer", primeError(429), 2)
+		core.ProcessTransfers(portfolio, ledger, config, rule, details)
+
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, 3, client.Calls("CreateWalletTransfer"))
+		assert.Equal(t, core.IdempotencyKey(rule.Name, scheduledAt, "eth-trading", "ETH"), transfers[0].IdempotencyKey)
+
+		record, err := ledger.Get(transfers[0].IdempotencyKey)
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusSubmitted, record.Status)
+	})
+
+	t.Run("permanent failures are not retried", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		portfolio, err := core.NewPortfolio("default", utils.NewRetryingClient(client, policy(config)), config)
+		assert.NoError(t, err)
+
+		client.FailNext("CreateWalletTransfer", primeError(400), 1)
+		core.ProcessTransfers(portfolio, ledger, config, rule, details)
+
+		assert.Empty(t, client.Transfers())
+		assert.Equal(t, 1, client.Calls("CreateWalletTransfer"))
+
+		record, err := ledger.Get(core.IdempotencyKey(rule.Name, scheduledAt, "eth-trading", "ETH"))
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusSubmissionFailed, record.Status)
+	})
+
+	t.Run("exhausted retries leave the transfer pending", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		portfolio, err := core.NewPortfolio("default", utils.NewRetryingClient(client, policy(config)), config)
+		assert.NoError(t, err)
+
+		client.FailNext("CreateWalletTransfer", primeError(503), 3)
+		core.ProcessTransfers(portfolio, ledger, config, rule, details)
+
+		assert.Empty(t, client.Transfers())
+		assert.Equal(t, 3, client.Calls("CreateWalletTransfer"))
+
+		key := core.IdempotencyKey(rule.Name, scheduledAt, "eth-trading", "ETH")
+		record, err := ledger.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, store.StatusPending, record.Status)
+
+		assert.NoError(t, core.ResumeTransfers(portfolio.Client, ledger, config))
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1)
+		assert.Equal(t, key, transfers[0].IdempotencyKey)
+	})
+	t.Run("the next run resubmits the pending transfer first", func(t *testing.T) {
+		client, ledger, config := newProcessTransfersFixture(t)
+		portfolio, err := core.NewPortfolio("default", utils.NewRetryingClient(client, policy(config)), config)
+		assert.NoError(t, err)
+
+		client.FailNext("CreateWalletTransfer", primeError(503), 3)
+		core.ProcessTransfers(portfolio, ledger, config, rule, details)
+		assert.Empty(t, client.Transfers())
+
+		next := details
+		next.ScheduledAt = scheduledAt.Add(time.Hour)
+		core.ProcessTransfers(portfolio, ledger, config, rule, next)
+
+		key := core.IdempotencyKey(rule.Name, scheduledAt, "eth-trading", "ETH")
+		transfers := client.Transfers()
+		assert.Len(t, transfers, 1, "the trading balance was swept by the resubmitted transfer")
+		assert.Equal(t, key, transfers[0].IdempotencyKey)
+
+		record, err := ledger.Get(key)
+		assert.NoError(t, err)
+		assert.NotEqual(t, store.StatusPending, record.Status)
+	})
+}
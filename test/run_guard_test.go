@@ -0,0 +1,270 @@
+package test
+
+import (
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type guardRun struct {
+	ruleName  string
+	walletIds []string
+}
+
+func TestRunGuardStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  []guardRun
+		run      guardRun
+		expected bool
+	}{
+		{
+			name:     "first run starts",
+			run:      guardRun{"sweep", []string{"eth-trading"}},
+			expected: true,
+		},
+		{
+			name:     "rule already running is skipped",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			run:      guardRun{"sweep", []string{"eth-trading"}},
+			expected: false,
+		},
+		{
+			name:     "rule already running is skipped whatever its wallets",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			run:      guardRun{"sweep", []string{"btc-trading"}},
+			expected: false,
+		},
+		{
+			name:     "other rule on other wallets starts",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			run:      guardRun{"topup", []string{"btc-trading"}},
+			expected: true,
+		},
+		{
+			name:     "rule without wallets starts",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			run:      guardRun{"topup", nil},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			guard := agent.NewRunGuard()
+			for _, run := range tc.running {
+				_, started := guard.Start(run.ruleName, run.walletIds)
+				assert.True(t, started)
+			}
+
+			done, started := guard.Start(tc.run.ruleName, tc.run.walletIds)
+			assert.Equal(t, tc.expected, started)
+			assert.Equal(t, tc.expected, done != nil)
+		})
+	}
+
+	t.Run("rule starts again once its run ended", func(t *testing.T) {
+		guard := agent.NewRunGuard()
+		done, started := guard.Start("sweep", []string{"eth-trading"})
+		assert.True(t, started)
+		done()
+
+		_, started = guard.Start("sweep", []string{"eth-trading"})
+		assert.True(t, started)
+	})
+}
+
+func TestRunGuardWalletsFree(t *testing.T) {
+	tests := []struct {
+		name      string
+		running   []guardRun
+		walletIds []string
+		expected  bool
+	}{
+		{
+			name:      "nothing running",
+			walletIds: []string{"eth-trading"},
+			expected:  true,
+		},
+		{
+			name:      "no wallets",
+			running:   []guardRun{{"sweep", []string{"eth-trading"}}},
+			walletIds: nil,
+			expected:  true,
+		},
+		{
+			name:      "wallets held by no rule",
+			running:   []guardRun{{"sweep", []string{"eth-trading"}}},
+			walletIds: []string{"btc-trading", "btc-vault"},
+			expected:  true,
+		},
+		{
+			name:      "one wallet held",
+			running:   []guardRun{{"sweep", []string{"eth-trading"}}},
+			walletIds: []string{"btc-trading", "eth-trading"},
+			expected:  false,
+		},
+		{
+			name: "wallets held by several rules",
+			running: []guardRun{
+				{"sweep", []string{"eth-trading"}},
+				{"topup", []string{"btc-trading"}},
+			},
+			walletIds: []string{"btc-trading", "eth-trading"},
+			expected:  false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			guard := agent.NewRunGuard()
+			for _, run := range tc.running {
+				_, started := guard.Start(run.ruleName, run.walletIds)
+				assert.True(t, started)
+			}
+
+			assert.Equal(t, tc.expected, guard.WalletsFree(tc.walletIds))
+		})
+	}
+
+	t.Run("wallets are released when the run ends", func(t *testing.T) {
+		guard := agent.NewRunGuard()
+		done, _ := guard.Start("sweep", []string{"eth-trading", "eth-vault"})
+		assert.False(t, guard.WalletsFree([]string{"eth-vault"}))
+
+		done()
+		assert.True(t, guard.WalletsFree([]string{"eth-trading", "eth-vault"}))
+	})
+}
+
+func TestRunGuardIsRunning(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  []guardRun
+		ended    []string
+		ruleName string
+		expected bool
+	}{
+		{
+			name:     "never started",
+			ruleName: "sweep",
+			expected: false,
+		},
+		{
+			name:     "started",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			ruleName: "sweep",
+			expected: true,
+		},
+		{
+			name:     "other rule started",
+			running:  []guardRun{{"topup", []string{"eth-trading"}}},
+			ruleName: "sweep",
+			expected: false,
+		},
+		{
+			name:     "ended",
+			running:  []guardRun{{"sweep", []string{"eth-trading"}}},
+			ended:    []string{"sweep"},
+			ruleName: "sweep",
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			guard := agent.NewRunGuard()
+			ends := make(map[string]func())
+			for _, run := range tc.running {
+				done, started := guard.Start(run.ruleName, run.walletIds)
+				assert.True(t, started)
+				ends[run.ruleName] = done
+			}
+			for _, ruleName := range tc.ended {
+				ends[ruleName]()
+			}
+
+			assert.Equal(t, tc.expected, guard.IsRunning(tc.ruleName))
+		})
+	}
+}
+
+func TestRunGuardConcurrentRuns(t *testing.T) {
+	t.Run("only one of concurrent runs of a rule starts", func(t *testing.T) {
+		guard := agent.NewRunGuard()
+
+		var mu sync.Mutex
+		var ends []func()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if done, started := guard.Start("sweep", []string{"eth-trading"}); started {
+					mu.Lock()
+					ends = append(ends, done)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, ends, 1)
+		assert.True(t, guard.IsRunning("sweep"))
+		ends[0]()
+		assert.False(t, guard.IsRunning("sweep"))
+	})
+
+	t.Run("rule waits for the wallets of another rule", func(t *testing.T) {
+		guard := agent.NewRunGuard()
+		endSweep, started := guard.Start("sweep", []string{"eth-trading", "eth-vault"})
+		assert.True(t, started)
+
+		topupStarted := make(chan func())
+		go func() {
+			done, _ := guard.Start("topup", []string{"eth-vault"})
+			topupStarted <- done
+		}()
+
+		select {
+		case <-topupStarted:
+			t.Fatal("topup started while sweep held its wallet")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.True(t, guard.IsRunning("topup"), "a waiting run counts as running")
+
+		endSweep()
+		select {
+		case endTopup := <-topupStarted:
+			assert.False(t, guard.WalletsFree([]string{"eth-vault"}))
+			assert.True(t, guard.WalletsFree([]string{"eth-trading"}))
+			endTopup()
+		case <-time.After(time.Second):
+			t.Fatal("topup did not start once sweep released its wallets")
+		}
+	})
+
+	t.Run("rules on disjoint wallets run together", func(t *testing.T) {
+		guard := agent.NewRunGuard()
+
+		var wg sync.WaitGroup
+		ends := make([]func(), 3)
+		for i, ruleName := range []string{"eth", "btc", "sol"} {
+			wg.Add(1)
+			go func(i int, ruleName string) {
+				defer wg.Done()
+				ends[i], _ = guard.Start(ruleName, []string{ruleName + "-trading"})
+			}(i, ruleName)
+		}
+		wg.Wait()
+
+		for _, ruleName := range []string{"eth", "btc", "sol"} {
+			assert.True(t, guard.IsRunning(ruleName))
+		}
+		for _, end := range ends {
+			end()
+		}
+	})
+}
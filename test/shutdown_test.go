@@ -0,0 +1,129 @@
+package test
+
+import (
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/agent"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const shutdownTestConfig = `
+rules:
+  - name: "hot_sweep"
+    direction: "trading_to_cold_custody"
+    schedule: "0 0 20 * * 1-5"
+    wallets:
+      - "ETH_cold"
+wallets:
+  - name: "ETH_cold"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-vault"
+daemon:
+  context_timeout_duration: 1
+  transfer_monitor_frequency: 1
+  transfer_monitor_timeout_duration: 60
+  shutdown_timeout: 5
+  ledger_path: "%s"
+`
+
+func TestGracefulShutdown(t *testing.T) {
+	dir := t.TempDir()
+	ledgerPath := filepath.Join(dir, "ledger.db")
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(shutdownTestConfig, ledgerPath)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+	client.SetTransactionStatus("TRANSACTION_CREATED")
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(client))
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+
+	stopChan := make(chan os.Signal, 1)
+	stopped := make(chan error, 1)
+	go func() { stopped <- sweeperAgent.Run(stopChan) }()
+
+	server := httptest.NewServer(sweeperAgent.AdminHandler())
+	response, err := http.Post(server.URL+"/rules/hot_sweep/trigger", "", nil)
+	assert.NoError(t, err)
+	response.Body.Close()
+	server.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(client.Transfers()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	stopChan <- syscall.SIGTERM
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("agent did not shut down")
+	}
+	assert.Less(t, time.Since(start), 5*time.Second, "trackers should be cancelled rather than awaited")
+
+	ledger, err := store.Open(ledgerPath)
+	assert.NoError(t, err)
+	defer ledger.Close()
+
+	handoff, err := ledger.ReadHandoff()
+	assert.NoError(t, err)
+	if assert.NotNil(t, handoff) && assert.Len(t, handoff.Transfers, 1) {
+		assert.Equal(t, client.Transfers()[0].IdempotencyKey, handoff.Transfers[0].IdempotencyKey)
+		assert.False(t, handoff.Transfers[0].IsTerminal())
+	}
+}
+
+func TestDryRunDoesNotResumeTransfers(t *testing.T) {
+	dir := t.TempDir()
+	ledgerPath := filepath.Join(dir, "ledger.db")
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(shutdownTestConfig, ledgerPath) + "  dry_run: true\n"
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := store.Open(ledgerPath)
+	assert.NoError(t, err)
+	assert.NoError(t, ledger.Create(&store.TransferRecord{
+		IdempotencyKey: "key",
+		RuleName:       "hot_sweep",
+		Request: prime.CreateWalletTransferRequest{
+			PortfolioId:         "portfolio",
+			SourceWalletId:      "eth-trading",
+			Symbol:              "ETH",
+			DestinationWalletId: "eth-vault",
+			Amount:              "1",
+			IdempotencyKey:      "key",
+		},
+		Status: store.StatusPending,
+	}))
+	assert.NoError(t, ledger.Close())
+
+	client := fake.NewPrimeClient("portfolio")
+	client.AddWallet("eth-trading", "TRADING", "ETH", "2")
+	client.AddWallet("eth-vault", "VAULT", "ETH", "0")
+
+	sweeperAgent, err := agent.NewSweeperAgent(configPath, utils.StaticClient(client))
+	assert.NoError(t, err)
+	assert.NoError(t, sweeperAgent.Setup())
+	assert.NoError(t, sweeperAgent.Close())
+
+	assert.Empty(t, client.Transfers(), "dry runs should not submit pending transfers")
+}
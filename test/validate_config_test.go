@@ -0,0 +1,202 @@
+package test
+
+import (
+	"errors"
+	"github.com/coinbase-samples/prime-sweeper-go/fake"
+	"github.com/coinbase-samples/prime-sweeper-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const invalidConfig = `rules:
+  - name: "hot_sweep"
+    direction: "trading_to_cold"
+    schedule: "0 0 20 * *"
+    wallets:
+      - "ETH_cold"
+      - "SOL_cold"
+  - name: "btc_sweep"
+    direction: "trading_to_cold_custody"
+    schedule: "@daily"
+    wallets:
+      - "BTC_hot"
+wallets:
+  - name: "ETH_cold"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-vault"
+  - name: "BTC_hot"
+    asset: "BTC"
+    type: "trading"
+    wallet_id: "btc-trading"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("reports every problem with its line", func(t *testing.T) {
+		_, err := utils.ValidateConfig(writeConfig(t, invalidConfig), nil)
+
+		var invalid *utils.ValidationError
+		assert.True(t, errors.As(err, &invalid))
+
+		type location struct {
+			Path string
+			Line int
+		}
+		var locations []location
+		for _, problem := range invalid.Problems {
+			locations = append(locations, location{problem.Path, problem.Line})
+		}
+		assert.ElementsMatch(t, []location{
+			{"wallets[1].type", 20},
+			{"rules[0].direction", 3},
+			{"rules[0].schedule", 4},
+			{"rules[0].wallets[1]", 7},
+			{"rules[1].wallets", 11},
+		}, locations)
+	})
+
+	t.Run("reports incomplete credentials sources", func(t *testing.T) {
+		config := `portfolios:
+  - name: "main"
+    credentials:
+      type: "vault"
+      address: "vault.example.com"
+  - name: "treasury"
+    credentials:
+      type: "keychain"
+rules: []
+wallets: []
+`
+		_, err := utils.ValidateConfig(writeConfig(t, config), nil)
+
+		var invalid *utils.ValidationError
+		assert.True(t, errors.As(err, &invalid))
+		var paths []string
+		for _, problem := range invalid.Problems {
+			paths = append(paths, problem.Path)
+		}
+		assert.Contains(t, paths, "portfolios[0].credentials")
+		assert.Contains(t, paths, "portfolios[1].credentials.type")
+	})
+
+	t.Run("checks cold to cold wallets", func(t *testing.T) {
+		config := `rules:
+  - name: "drain"
+    direction: "cold_custody_to_cold_custody"
+    schedule: "@daily"
+    wallets:
+      - "ETH_ops"
+      - "BTC_ops"
+    destination_wallets:
+      - "ETH_ops"
+  - name: "rebalance"
+    direction: "cold_custody_to_cold_custody"
+    schedule: "@daily"
+    wallets:
+      - "BTC_ops"
+  - name: "cold_sweep"
+    direction: "cold_custody_to_trading"
+    schedule: "@daily"
+    wallets:
+      - "BTC_ops"
+    destination_wallets:
+      - "ETH_ops"
+wallets:
+  - name: "ETH_ops"
+    asset: "ETH"
+    type: "cold_custody"
+    wallet_id: "eth-ops"
+  - name: "BTC_ops"
+    asset: "BTC"
+    type: "cold_custody"
+    wallet_id: "btc-ops"
+`
+		_, err := utils.ValidateConfig(writeConfig(t, config), nil)
+
+		var invalid *utils.ValidationError
+		assert.True(t, errors.As(err, &invalid))
+		var messages []string
+		for _, problem := range invalid.Problems {
+			messages = append(messages, problem.Path+": "+problem.Message)
+		}
+		assert.ElementsMatch(t, []string{
+			"rules[0].destination_wallets[0]: wallet 'ETH_ops' is both a source and a destination of rule 'drain'",
+			"rules[0].destination_wallets: no destination wallet for asset 'BTC' in rule 'drain'",
+			"rules[1].wallets: rule 'rebalance' needs at least two wallets of asset 'BTC' to rebalance",
+			"rules[2].destination_wallets: destination_wallets are only supported on cold_custody_to_cold_custody rules: cold_sweep",
+		}, messages)
+	})
+
+	t.Run("reports unknown fields and mistyped values", func(t *testing.T) {
+		config := `rules:
+  - name: "hot_sweep"
+    direction: "trading_to_cold_custody"
+    schedule: "@daily"
+    walets:
+      - "ETH_cold"
+wallets: []
+daemon:
+  transfer_monitor_frequency: 5s
+  shutdown_timeout: "often"
+  dry_runn: true
+`
+		_, err := utils.ValidateConfig(writeConfig(t, config), nil)
+
+		var invalid *utils.ValidationError
+		assert.True(t, errors.As(err, &invalid))
+		var messages []string
+		for _, problem := range invalid.Problems {
+			if problem.Path == "" {
+				messages = append(messages, problem.String())
+			}
+		}
+		assert.ElementsMatch(t, []string{
+			"line 5: field walets not found in type model.Rule",
+			"line 9: '5s' must be a number of seconds, without a unit",
+			"line 10: 'often' must be a number of seconds",
+			"line 11: field dry_runn not found in type model.DaemonConfig",
+		}, messages)
+	})
+
+	t.Run("durations are numbers of seconds", func(t *testing.T) {
+		config, err := utils.LoadConfig(writeConfig(t, `rules: []
+wallets: []
+daemon: {transfer_monitor_frequency: 5, shutdown_timeout: 0x10, transfer_monitor_timeout_duration: 2}
+`))
+		if assert.NoError(t, err) {
+			assert.Equal(t, 5*time.Second, config.Daemon.TransferMonitorFrequency.Duration())
+			assert.Equal(t, 16*time.Second, config.Daemon.ShutdownTimeout.Duration())
+			assert.Equal(t, 2*time.Minute, config.Daemon.TransferMonitorTimeoutDuration.Duration())
+		}
+	})
+
+	t.Run("offline validation needs no Prime access", func(t *testing.T) {
+		config, err := utils.ValidateConfig(writeConfig(t, adminTestConfig), nil)
+		assert.NoError(t, err)
+		assert.Len(t, config.Rules, 1)
+	})
+
+	t.Run("online phase checks wallets against Prime", func(t *testing.T) {
+		client := fake.NewPrimeClient("portfolio")
+		client.AddWallet("eth-vault", "VAULT", "BTC", "0")
+
+		_, err := utils.ValidateConfig(writeConfig(t, adminTestConfig), utils.StaticClient(client))
+
+		var invalid *utils.ValidationError
+		if assert.True(t, errors.As(err, &invalid)) && assert.Len(t, invalid.Problems, 1) {
+			assert.Equal(t, "wallets[0].asset", invalid.Problems[0].Path)
+			assert.Contains(t, invalid.Problems[0].Message, "asset mismatch")
+		}
+	})
+}
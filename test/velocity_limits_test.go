@@ -0,0 +1,80 @@
+package test
+
+import (
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/core"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"github.com/coinbase-samples/prime-sweeper-go/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCheckVelocityLimits(t *testing.T) {
+	now := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+	history := []store.TransferRecord{
+		{Request: prime.CreateWalletTransferRequest{Symbol: "BTC", Amount: "4"}, Status: "TRANSACTION_DONE", CreatedAt: now.Add(-time.Hour)},
+		{Request: prime.CreateWalletTransferRequest{Symbol: "BTC", Amount: "3"}, Status: store.StatusSubmitted, CreatedAt: now.Add(-2 * time.Hour)},
+		{Request: prime.CreateWalletTransferRequest{Symbol: "BTC", Amount: "5"}, Status: "TRANSACTION_DONE", CreatedAt: now.Add(-25 * time.Hour)},
+		{Request: prime.CreateWalletTransferRequest{Symbol: "BTC", Amount: "5"}, Status: "TRANSACTION_REJECTED", CreatedAt: now.Add(-time.Hour)},
+		{Request: prime.CreateWalletTransferRequest{Symbol: "ETH", Amount: "100"}, Status: store.StatusSubmitted, CreatedAt: now.Add(-time.Minute)},
+	}
+	btc := func(amount string) []*prime.CreateWalletTransferRequest {
+		return []*prime.CreateWalletTransferRequest{{Symbol: "BTC", Amount: amount}}
+	}
+
+	tests := []struct {
+		name      string
+		limit     model.VelocityLimit
+		sequence  []*prime.CreateWalletTransferRequest
+		expectErr bool
+	}{
+		{
+			name:     "amount within window",
+			limit:    model.VelocityLimit{Asset: "BTC", Window: 86400, MaxAmount: "10"},
+			sequence: btc("3"),
+		},
+		{
+			name:      "amount over window",
+			limit:     model.VelocityLimit{Asset: "BTC", Window: 86400, MaxAmount: "10"},
+			sequence:  btc("3.1"),
+			expectErr: true,
+		},
+		{
+			name:     "older transfers fall out of window",
+			limit:    model.VelocityLimit{Asset: "BTC", Window: 5400, MaxAmount: "5"},
+			sequence: btc("1"),
+		},
+		{
+			name:     "other assets do not count",
+			limit:    model.VelocityLimit{Asset: "ETH", Window: 86400, MaxAmount: "100"},
+			sequence: btc("50"),
+		},
+		{
+			name:      "count across assets",
+			limit:     model.VelocityLimit{Window: 3600 * 3, MaxCount: 3},
+			sequence:  btc("1"),
+			expectErr: true,
+		},
+		{
+			name:  "chunks count individually",
+			limit: model.VelocityLimit{Asset: "BTC", Window: 3600 * 3, MaxCount: 3},
+			sequence: []*prime.CreateWalletTransferRequest{
+				{Symbol: "BTC", Amount: "1"},
+				{Symbol: "BTC", Amount: "1"},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := core.CheckVelocityLimits([]model.VelocityLimit{tc.limit}, history, tc.sequence, now)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
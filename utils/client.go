@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/credentials"
+	"net/http"
+	"sync"
+)
+
+// PrimeClient is the subset of the Prime API used by the sweeper.
+type PrimeClient interface {
+	PortfolioId() string
+	ListWallets(ctx context.Context, request *prime.ListWalletsRequest) (*prime.ListWalletsResponse, error)
+	GetWallet(ctx context.Context, request *prime.GetWalletRequest) (*prime.GetWalletResponse, error)
+	GetWalletBalance(ctx context.Context, request *prime.GetWalletBalanceRequest) (*prime.GetWalletBalanceResponse, error)
+	CreateWalletTransfer(ctx context.Context, request *prime.CreateWalletTransferRequest) (*prime.CreateWalletTransferResponse, error)
+	GetActivity(ctx context.Context, request *prime.GetActivityRequest) (*prime.GetActivityResponse, error)
+	GetTransaction(ctx context.Context, request *prime.GetTransactionRequest) (*prime.GetTransactionResponse, error)
+}
+
+type primeClient struct {
+	*prime.Client
+}
+
+func (c *primeClient) PortfolioId() string {
+	return c.Credentials.PortfolioId
+}
+
+func NewPrimeClient(client *prime.Client) PrimeClient {
+	return &primeClient{Client: client}
+}
+
+// rotatingClient asks its provider for the current credentials on every call
+// and rebuilds the SDK client when they change, so rotated credentials take
+// effect without a restart. The provider is expected to cache.
+type rotatingClient struct {
+	provider    credentials.Provider
+	portfolioId string
+
+	mu          sync.Mutex
+	credentials *prime.Credentials
+	client      *prime.Client
+}
+
+// NewRotatingClient returns a client backed by provider, failing if the
+// initial credentials cannot be loaded. The portfolio is fixed by the initial
+// credentials; rotated credentials for another portfolio are refused.
+func NewRotatingClient(ctx context.Context, provider credentials.Provider) (PrimeClient, error) {
+	c := &rotatingClient{provider: provider}
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.portfolioId = client.Credentials.PortfolioId
+	return c, nil
+}
+
+func (c *rotatingClient) current(ctx context.Context) (*prime.Client, error) {
+	credentials, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil && credentials == c.credentials {
+		return c.client, nil
+	}
+	if c.portfolioId != "" && credentials.PortfolioId != c.portfolioId {
+		return nil, fmt.Errorf("rotated credentials are for portfolio %s, expected %s", credentials.PortfolioId, c.portfolioId)
+	}
+	c.credentials = credentials
+	c.client = prime.NewClient(credentials, http.Client{})
+	return c.client, nil
+}
+
+func (c *rotatingClient) PortfolioId() string {
+	return c.portfolioId
+}
+
+func (c *rotatingClient) ListWallets(ctx context.Context, request *prime.ListWalletsRequest) (*prime.ListWalletsResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListWallets(ctx, request)
+}
+
+func (c *rotatingClient) GetWallet(ctx context.Context, request *prime.GetWalletRequest) (*prime.GetWalletResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetWallet(ctx, request)
+}
+
+func (c *rotatingClient) GetWalletBalance(ctx context.Context, request *prime.GetWalletBalanceRequest) (*prime.GetWalletBalanceResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetWalletBalance(ctx, request)
+}
+
+func (c *rotatingClient) CreateWalletTransfer(ctx context.Context, request *prime.CreateWalletTransferRequest) (*prime.CreateWalletTransferResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateWalletTransfer(ctx, request)
+}
+
+func (c *rotatingClient) GetActivity(ctx context.Context, request *prime.GetActivityRequest) (*prime.GetActivityResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetActivity(ctx, request)
+}
+
+func (c *rotatingClient) GetTransaction(ctx context.Context, request *prime.GetTransactionRequest) (*prime.GetTransactionResponse, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTransaction(ctx, request)
+}
@@ -3,74 +3,586 @@ package utils
 import (
 	"fmt"
 	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/credentials"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
-	"github.com/go-yaml/yaml"
+	"github.com/coinbase-samples/prime-sweeper-go/notify"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
+	"net/url"
 	"os"
+	"sort"
 )
 
-func ReadConfig(filename string) (*model.Config, error) {
-	bytes, err := os.ReadFile(filename)
+// ReadConfig loads the config file and validates it, including the cold
+// wallets against Prime.
+func ReadConfig(filename string, clients ClientResolver) (*model.Config, error) {
+	return ValidateConfig(filename, clients)
+}
+
+// LoadConfig parses the config file without validating it. Unknown fields and
+// values of the wrong type are still rejected.
+func LoadConfig(filename string) (*model.Config, error) {
+	config, _, problems, err := loadConfig(filename)
 	if err != nil {
 		return nil, err
 	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{File: filename, Problems: problems}
+	}
+	return config, nil
+}
 
-	config := &model.Config{}
+// loadConfig reads and decodes the config file, returning the raw content and
+// the decoding problems along with the config.
+func loadConfig(filename string) (*model.Config, []byte, []Problem, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	if err = yaml.Unmarshal(bytes, config); err != nil {
-		return nil, err
+	config, problems, err := decodeConfig(bytes)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	if err := validateConfig(config); err != nil {
+	return config, bytes, problems, nil
+}
+
+// ValidateConfig loads the config file and runs every static check, reporting
+// all problems at once as a *ValidationError. When clients is not nil and the
+// static checks pass, the cold wallets are also checked against Prime.
+func ValidateConfig(filename string, clients ClientResolver) (*model.Config, error) {
+	config, raw, problems, err := loadConfig(filename)
+	if err != nil {
 		return nil, err
 	}
 
-	return config, nil
-}
+	v := &validator{problems: problems}
+	v.checkUniqueRuleNames(config)
+	v.checkPortfolios(config)
+	v.checkCredentials(config)
+	v.checkRulesAndWallets(config)
+	v.checkRetention(config)
+	v.checkThresholds(config)
+	v.checkTargetBalances(config)
+	v.checkAllocation(config)
+	v.checkMaxTransferAmounts(config)
+	v.checkLimits(config)
+	v.checkNotifications(config)
+	v.checkRetry(config)
+	v.checkRateLimit(config)
 
-func validateConfig(config *model.Config) error {
-	if err := checkUniqueRuleNames(config); err != nil {
-		return err
+	if len(v.problems) == 0 && clients != nil {
+		v.validateColdWallets(config, clients)
 	}
 
-	if err := checkRulesAndWallets(config); err != nil {
-		return err
+	if err := v.err(filename, raw); err != nil {
+		return nil, err
 	}
-	return validateColdWallets(config)
+	return config, nil
 }
 
-func checkUniqueRuleNames(config *model.Config) error {
+func (v *validator) checkUniqueRuleNames(config *model.Config) {
 	ruleNames := make(map[string]bool)
-	for _, rule := range config.Rules {
-		if _, exists := ruleNames[rule.Name]; exists {
-			return fmt.Errorf("duplicate rule name: %s", rule.Name)
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			v.addf(fieldPath("rules", i), "rule name not specified")
+			continue
+		}
+		if ruleNames[rule.Name] {
+			v.addf(fieldPath("rules", i, "name"), "duplicate rule name: %s", rule.Name)
 		}
 		ruleNames[rule.Name] = true
 	}
-	return nil
 }
 
-func checkRulesAndWallets(config *model.Config) error {
+func (v *validator) checkPortfolios(config *model.Config) {
+	portfolioNames := make(map[string]bool)
+	for i, portfolio := range config.Portfolios {
+		if portfolio.Name == "" {
+			v.addf(fieldPath("portfolios", i), "portfolio name not specified")
+			continue
+		}
+		if portfolioNames[portfolio.Name] {
+			v.addf(fieldPath("portfolios", i, "name"), "duplicate portfolio name: %s", portfolio.Name)
+		}
+		portfolioNames[portfolio.Name] = true
+	}
+	for _, portfolio := range GetPortfolios(config) {
+		portfolioNames[portfolio.Name] = true
+	}
+
+	walletPortfolios := make(map[string]string)
+	for i, wallet := range config.Wallets {
+		portfolioName := WalletPortfolio(config, wallet)
+		if !portfolioNames[portfolioName] {
+			v.addf(fieldPath("wallets", i, "portfolio"), "wallet '%s' references unknown portfolio '%s'", wallet.Name, wallet.Portfolio)
+		}
+		walletPortfolios[wallet.Name] = portfolioName
+	}
+
+	for i, rule := range config.Rules {
+		portfolioName := RulePortfolio(config, rule)
+		if !portfolioNames[portfolioName] {
+			v.addf(fieldPath("rules", i, "portfolio"), "rule '%s' references unknown portfolio '%s'", rule.Name, rule.Portfolio)
+			continue
+		}
+		for j, walletName := range rule.Wallets {
+			if walletPortfolio, exists := walletPortfolios[walletName]; exists && walletPortfolio != portfolioName {
+				v.addf(fieldPath("rules", i, "wallets", j), "wallet '%s' in rule '%s' belongs to portfolio '%s', not '%s'",
+					walletName, rule.Name, walletPortfolio, portfolioName)
+			}
+		}
+		for j, walletName := range rule.DestinationWallets {
+			if walletPortfolio, exists := walletPortfolios[walletName]; exists && walletPortfolio != portfolioName {
+				v.addf(fieldPath("rules", i, "destination_wallets", j), "wallet '%s' in rule '%s' belongs to portfolio '%s', not '%s'",
+					walletName, rule.Name, walletPortfolio, portfolioName)
+			}
+		}
+	}
+}
+
+func (v *validator) checkCredentials(config *model.Config) {
+	for i, portfolio := range config.Portfolios {
+		source := portfolio.Credentials
+		if source == nil {
+			continue
+		}
+		path := fieldPath("portfolios", i, "credentials")
+		switch source.Type {
+		case credentials.SourceEnv:
+		case credentials.SourceFile:
+			if source.Path == "" {
+				v.addf(path, "file credentials of portfolio '%s' need a path", portfolio.Name)
+			}
+		case credentials.SourceExec:
+			if len(source.Command) == 0 {
+				v.addf(path, "exec credentials of portfolio '%s' need a command", portfolio.Name)
+			}
+		case credentials.SourceVault:
+			if !isHttpUrl(source.Address) || source.Path == "" {
+				v.addf(path, "vault credentials of portfolio '%s' need an http or https address and a path", portfolio.Name)
+			}
+		default:
+			v.addf(fieldPath("portfolios", i, "credentials", "type"), "portfolio '%s' has unknown credentials type '%s'", portfolio.Name, source.Type)
+		}
+		if source.RefreshInterval < 0 {
+			v.addf(fieldPath("portfolios", i, "credentials", "refresh_interval"), "credentials refresh_interval of portfolio '%s' must not be negative", portfolio.Name)
+		}
+		if portfolio.CredentialsEnv != "" {
+			v.addf(fieldPath("portfolios", i, "credentials_env"), "portfolio '%s' sets both credentials_env and credentials", portfolio.Name)
+		}
+	}
+}
+
+var transferDirections = map[string]bool{
+	string(model.HotToCold):  true,
+	string(model.ColdToHot):  true,
+	string(model.ColdToCold): true,
+}
+
+func (v *validator) checkRulesAndWallets(config *model.Config) {
 	walletNames := make(map[string]bool)
-	for _, rule := range config.Rules {
-		if rule.Schedule == "" {
-			return fmt.Errorf("schedule not specified for rule: %s", rule.Name)
+	for i, wallet := range config.Wallets {
+		if wallet.Name == "" {
+			v.addf(fieldPath("wallets", i), "wallet name not specified")
+		} else if walletNames[wallet.Name] {
+			v.addf(fieldPath("wallets", i, "name"), "duplicate wallet name: %s", wallet.Name)
 		}
-		for _, walletName := range rule.Wallets {
+		walletNames[wallet.Name] = true
+
+		if wallet.Asset == "" {
+			v.addf(fieldPath("wallets", i), "asset not specified for wallet '%s'", wallet.Name)
+		}
+		if wallet.WalletId == "" {
+			v.addf(fieldPath("wallets", i), "wallet_id not specified for wallet '%s'", wallet.Name)
+		}
+		if wallet.Type != model.ColdCustodyWallet {
+			v.addf(fieldPath("wallets", i, "type"), "unknown type '%s' for wallet '%s', expected '%s'",
+				wallet.Type, wallet.Name, model.ColdCustodyWallet)
+		}
+	}
+
+	for i, rule := range config.Rules {
+		if !transferDirections[rule.Direction] {
+			v.addf(fieldPath("rules", i, "direction"), "unknown direction '%s' for rule '%s', expected '%s', '%s' or '%s'",
+				rule.Direction, rule.Name, model.HotToCold, model.ColdToHot, model.ColdToCold)
+		}
+
+		if rule.Schedule == "" && len(rule.Thresholds) == 0 {
+			v.addf(fieldPath("rules", i), "schedule not specified for rule: %s", rule.Name)
+		} else if rule.Schedule != "" {
+			if _, err := ScheduleParser.Parse(rule.Schedule); err != nil {
+				v.addf(fieldPath("rules", i, "schedule"), "invalid schedule for rule '%s': %v", rule.Name, err)
+			}
+		}
+
+		if len(rule.Wallets) == 0 {
+			v.addf(fieldPath("rules", i), "no wallets listed for rule '%s'", rule.Name)
+		}
+		for j, walletName := range rule.Wallets {
+			if !walletExists(walletName, config.Wallets) {
+				v.addf(fieldPath("rules", i, "wallets", j), "wallet '%s' in rule '%s' does not exist", walletName, rule.Name)
+			}
+		}
+
+		for j, walletName := range rule.DestinationWallets {
 			if !walletExists(walletName, config.Wallets) {
-				return fmt.Errorf("wallet '%s' in rule '%s' does not exist", walletName, rule.Description)
+				v.addf(fieldPath("rules", i, "destination_wallets", j), "destination wallet '%s' in rule '%s' does not exist", walletName, rule.Name)
 			}
 		}
+
+		switch rule.Direction {
+		case string(model.HotToCold):
+			v.checkColdWalletPerAsset(config, i, rule)
+		case string(model.ColdToCold):
+			v.checkColdToColdWallets(config, i, rule)
+		}
+		if len(rule.DestinationWallets) > 0 && rule.Direction != string(model.ColdToCold) {
+			v.addf(fieldPath("rules", i, "destination_wallets"), "destination_wallets are only supported on %s rules: %s", model.ColdToCold, rule.Name)
+		}
 	}
-	for _, wallet := range config.Wallets {
-		if _, exists := walletNames[wallet.Name]; exists {
-			return fmt.Errorf("duplicate wallet name: %s", wallet.Name)
+}
+
+// checkColdToColdWallets makes sure that a cold-to-cold rule can move every
+// asset it holds: a drain needs a destination wallet of each asset that is not
+// also a source, and a rebalance needs at least two wallets of each asset.
+func (v *validator) checkColdToColdWallets(config *model.Config, index int, rule model.Rule) {
+	assets := make(map[string]int)
+	for _, walletName := range rule.Wallets {
+		for _, wallet := range config.Wallets {
+			if wallet.Name == walletName && wallet.Asset != "" {
+				assets[wallet.Asset]++
+			}
+		}
+	}
+
+	if len(rule.DestinationWallets) == 0 {
+		for _, asset := range sortedKeys(assets) {
+			if assets[asset] < 2 {
+				v.addf(fieldPath("rules", index, "wallets"), "rule '%s' needs at least two wallets of asset '%s' to rebalance", rule.Name, asset)
+			}
+		}
+		return
+	}
+
+	sources := make(map[string]bool)
+	for _, walletName := range rule.Wallets {
+		sources[walletName] = true
+	}
+	destinationAssets := make(map[string]bool)
+	for j, walletName := range rule.DestinationWallets {
+		if sources[walletName] {
+			v.addf(fieldPath("rules", index, "destination_wallets", j), "wallet '%s' is both a source and a destination of rule '%s'", walletName, rule.Name)
+		}
+		for _, wallet := range config.Wallets {
+			if wallet.Name == walletName {
+				destinationAssets[wallet.Asset] = true
+			}
+		}
+	}
+	for _, asset := range sortedKeys(assets) {
+		if !destinationAssets[asset] {
+			v.addf(fieldPath("rules", index, "destination_wallets"), "no destination wallet for asset '%s' in rule '%s'", asset, rule.Name)
+		}
+	}
+}
+
+// checkColdWalletPerAsset makes sure that every asset a hot-to-cold rule
+// sweeps has a cold custody wallet among the rule's wallets to receive it.
+func (v *validator) checkColdWalletPerAsset(config *model.Config, index int, rule model.Rule) {
+	assets := make(map[string]bool)
+	coldAssets := make(map[string]bool)
+	for _, walletName := range rule.Wallets {
+		for _, wallet := range config.Wallets {
+			if wallet.Name != walletName || wallet.Asset == "" {
+				continue
+			}
+			assets[wallet.Asset] = true
+			if wallet.Type == model.ColdCustodyWallet {
+				coldAssets[wallet.Asset] = true
+			}
+		}
+	}
+	for asset := range rule.Thresholds {
+		assets[asset] = true
+	}
+
+	for _, asset := range sortedKeys(assets) {
+		if !coldAssets[asset] {
+			v.addf(fieldPath("rules", index, "wallets"), "no cold custody wallet for asset '%s' in rule '%s'", asset, rule.Name)
+		}
+	}
+}
+
+func (v *validator) checkRetention(config *model.Config) {
+	for i, rule := range config.Rules {
+		if err := checkRetentionValues(rule.RetainAmount, rule.RetainPercentage); err != nil {
+			v.addf(fieldPath("rules", i), "invalid retention for rule '%s': %v", rule.Name, err)
+		}
+	}
+	for i, wallet := range config.Wallets {
+		if err := checkRetentionValues(wallet.RetainAmount, wallet.RetainPercentage); err != nil {
+			v.addf(fieldPath("wallets", i), "invalid retention for wallet '%s': %v", wallet.Name, err)
+		}
+	}
+}
+
+func checkRetentionValues(retainAmount, retainPercentage string) error {
+	if retainAmount != "" {
+		amount, err := decimal.NewFromString(retainAmount)
+		if err != nil {
+			return fmt.Errorf("cannot parse retain_amount '%s': %w", retainAmount, err)
+		}
+		if amount.IsNegative() {
+			return fmt.Errorf("retain_amount must not be negative")
+		}
+	}
+	if retainPercentage != "" {
+		percentage, err := decimal.NewFromString(retainPercentage)
+		if err != nil {
+			return fmt.Errorf("cannot parse retain_percentage '%s': %w", retainPercentage, err)
+		}
+		if percentage.IsNegative() || percentage.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("retain_percentage must be between 0 and 100")
 		}
-		walletNames[wallet.Name] = true
 	}
 	return nil
 }
 
+func (v *validator) checkThresholds(config *model.Config) {
+	for i, rule := range config.Rules {
+		if len(rule.Thresholds) > 0 && rule.Direction != string(model.HotToCold) {
+			v.addf(fieldPath("rules", i, "thresholds"), "thresholds are only supported on %s rules: %s", model.HotToCold, rule.Name)
+		}
+		for _, asset := range sortedKeys(rule.Thresholds) {
+			value := rule.Thresholds[asset]
+			threshold, err := decimal.NewFromString(value)
+			if err != nil {
+				v.addf(fieldPath("rules", i, "thresholds", asset), "cannot parse threshold '%s' for asset '%s' in rule '%s': %v", value, asset, rule.Name, err)
+			} else if !threshold.IsPositive() {
+				v.addf(fieldPath("rules", i, "thresholds", asset), "threshold for asset '%s' in rule '%s' must be positive", asset, rule.Name)
+			}
+		}
+		if rule.MinSweepAmount != "" {
+			minSweepAmount, err := decimal.NewFromString(rule.MinSweepAmount)
+			if err != nil {
+				v.addf(fieldPath("rules", i, "min_sweep_amount"), "cannot parse min_sweep_amount '%s' in rule '%s': %v", rule.MinSweepAmount, rule.Name, err)
+			} else if minSweepAmount.IsNegative() {
+				v.addf(fieldPath("rules", i, "min_sweep_amount"), "min_sweep_amount in rule '%s' must not be negative", rule.Name)
+			}
+		}
+	}
+}
+
+// checkTargetBalances makes sure that target-based rules fund trading
+// wallets and that every asset the rule moves has a target.
+func (v *validator) checkTargetBalances(config *model.Config) {
+	for i, rule := range config.Rules {
+		if len(rule.TargetBalances) == 0 {
+			continue
+		}
+		if rule.Direction != string(model.ColdToHot) {
+			v.addf(fieldPath("rules", i, "target_balances"), "target_balances are only supported on %s rules: %s", model.ColdToHot, rule.Name)
+			continue
+		}
+		for _, asset := range sortedKeys(rule.TargetBalances) {
+			value := rule.TargetBalances[asset]
+			target, err := decimal.NewFromString(value)
+			if err != nil {
+				v.addf(fieldPath("rules", i, "target_balances", asset), "cannot parse target balance '%s' for asset '%s' in rule '%s': %v", value, asset, rule.Name, err)
+			} else if target.IsNegative() {
+				v.addf(fieldPath("rules", i, "target_balances", asset), "target balance for asset '%s' in rule '%s' must not be negative", asset, rule.Name)
+			}
+		}
+		for _, walletName := range rule.Wallets {
+			for _, wallet := range config.Wallets {
+				if wallet.Name != walletName {
+					continue
+				}
+				if _, exists := rule.TargetBalances[wallet.Asset]; !exists {
+					v.addf(fieldPath("rules", i, "target_balances"), "no target balance for asset '%s' of wallet '%s' in rule '%s'", wallet.Asset, wallet.Name, rule.Name)
+				}
+			}
+		}
+	}
+}
+
+var allocationStrategies = map[string]bool{
+	model.AllocationFirst:        true,
+	model.AllocationWeighted:     true,
+	model.AllocationFillToCap:    true,
+	model.AllocationRoundRobin:   true,
+	model.AllocationLeastBalance: true,
+}
+
+func (v *validator) checkAllocation(config *model.Config) {
+	for i, rule := range config.Rules {
+		if rule.Allocation == "" {
+			continue
+		}
+		if !allocationStrategies[rule.Allocation] {
+			v.addf(fieldPath("rules", i, "allocation"), "unknown allocation '%s' in rule '%s'", rule.Allocation, rule.Name)
+		}
+		drains := rule.Direction == string(model.ColdToCold) && len(rule.DestinationWallets) > 0
+		if rule.Direction != string(model.HotToCold) && !drains {
+			v.addf(fieldPath("rules", i, "allocation"), "allocation is only supported on %s rules and %s rules with destination_wallets: %s",
+				model.HotToCold, model.ColdToCold, rule.Name)
+		}
+	}
+	for i, wallet := range config.Wallets {
+		if wallet.Weight != "" {
+			weight, err := decimal.NewFromString(wallet.Weight)
+			if err != nil {
+				v.addf(fieldPath("wallets", i, "weight"), "cannot parse weight '%s' for wallet '%s': %v", wallet.Weight, wallet.Name, err)
+			} else if !weight.IsPositive() {
+				v.addf(fieldPath("wallets", i, "weight"), "weight for wallet '%s' must be positive", wallet.Name)
+			}
+		}
+		if wallet.Cap != "" {
+			capAmount, err := decimal.NewFromString(wallet.Cap)
+			if err != nil {
+				v.addf(fieldPath("wallets", i, "cap"), "cannot parse cap '%s' for wallet '%s': %v", wallet.Cap, wallet.Name, err)
+			} else if capAmount.IsNegative() {
+				v.addf(fieldPath("wallets", i, "cap"), "cap for wallet '%s' must not be negative", wallet.Name)
+			}
+		}
+	}
+}
+
+func (v *validator) checkMaxTransferAmounts(config *model.Config) {
+	for i, rule := range config.Rules {
+		if err := checkMaxTransferAmount(rule.MaxTransferAmount); err != nil {
+			v.addf(fieldPath("rules", i, "max_transfer_amount"), "rule '%s': %v", rule.Name, err)
+		}
+	}
+	for i, wallet := range config.Wallets {
+		if err := checkMaxTransferAmount(wallet.MaxTransferAmount); err != nil {
+			v.addf(fieldPath("wallets", i, "max_transfer_amount"), "wallet '%s': %v", wallet.Name, err)
+		}
+	}
+}
+
+func checkMaxTransferAmount(value string) error {
+	if value == "" {
+		return nil
+	}
+	maxAmount, err := decimal.NewFromString(value)
+	if err != nil {
+		return fmt.Errorf("cannot parse max_transfer_amount '%s': %w", value, err)
+	}
+	if maxAmount.LessThan(decimal.New(1, -8)) {
+		return fmt.Errorf("max_transfer_amount must be at least 0.00000001")
+	}
+	return nil
+}
+
+func (v *validator) checkLimits(config *model.Config) {
+	for i, limit := range config.Limits {
+		path := fieldPath("limits", i)
+		if limit.Window <= 0 {
+			v.addf(path, "limit %d: window must be a positive number of seconds", i)
+		}
+		if limit.MaxAmount == "" && limit.MaxCount == 0 {
+			v.addf(path, "limit %d: max_amount or max_count must be set", i)
+		}
+		if limit.MaxCount < 0 {
+			v.addf(fieldPath("limits", i, "max_count"), "limit %d: max_count must not be negative", i)
+		}
+		if limit.MaxAmount != "" {
+			if limit.Asset == "" {
+				v.addf(path, "limit %d: max_amount requires an asset", i)
+			}
+			maxAmount, err := decimal.NewFromString(limit.MaxAmount)
+			if err != nil {
+				v.addf(fieldPath("limits", i, "max_amount"), "limit %d: cannot parse max_amount '%s': %v", i, limit.MaxAmount, err)
+			} else if maxAmount.IsNegative() {
+				v.addf(fieldPath("limits", i, "max_amount"), "limit %d: max_amount must not be negative", i)
+			}
+		}
+	}
+}
+
+func (v *validator) checkRetry(config *model.Config) {
+	retry := config.Daemon.Retry
+	if retry.MaxAttempts < 0 || retry.InitialBackoffMs < 0 || retry.MaxBackoffMs < 0 {
+		v.addf("daemon.retry", "daemon retry settings must not be negative")
+		return
+	}
+	policy := GetRetryPolicy(config)
+	if policy.MaxBackoff < policy.InitialBackoff {
+		v.addf("daemon.retry", "daemon retry max_backoff_ms must not be lower than initial_backoff_ms")
+	}
+}
+
+func (v *validator) checkRateLimit(config *model.Config) {
+	rateLimit := config.Daemon.RateLimit
+	if rateLimit.RequestsPerSecond < 0 || rateLimit.Burst < 0 {
+		v.addf("daemon.rate_limit", "daemon rate_limit settings must not be negative")
+	}
+}
+
+func (v *validator) checkNotifications(config *model.Config) {
+	notifications := config.Notifications
+	if notifications.WebhookUrl != "" && !isHttpUrl(notifications.WebhookUrl) {
+		v.addf("notifications.webhook_url", "notifications webhook_url must be an http or https URL")
+	}
+	if notifications.ApprovalReminderFrequency < 0 {
+		v.addf("notifications.approval_reminder_frequency", "notifications approval_reminder_frequency must not be negative")
+	}
+
+	ruleNames := make(map[string]bool)
+	for _, rule := range config.Rules {
+		ruleNames[rule.Name] = true
+	}
+	eventTypes := make(map[string]bool)
+	for _, eventType := range notify.EventTypes {
+		eventTypes[eventType] = true
+	}
+
+	sinkNames := make(map[string]bool)
+	for i, sink := range notifications.Sinks {
+		path := fieldPath("notifications", "sinks", i)
+		if sink.Name == "" {
+			v.addf(path, "notification sink name not specified")
+		} else if sinkNames[sink.Name] {
+			v.addf(fieldPath("notifications", "sinks", i, "name"), "duplicate notification sink name: %s", sink.Name)
+		}
+		sinkNames[sink.Name] = true
+
+		switch sink.Type {
+		case notify.SinkWebhook, notify.SinkSlack:
+			if !isHttpUrl(sink.Url) {
+				v.addf(path, "notification sink '%s' needs an http or https url", sink.Name)
+			}
+		case notify.SinkSmtp:
+			if sink.SmtpHost == "" || sink.SmtpPort <= 0 || sink.From == "" || len(sink.To) == 0 {
+				v.addf(path, "notification sink '%s' needs smtp_host, smtp_port, from and to", sink.Name)
+			}
+		default:
+			v.addf(fieldPath("notifications", "sinks", i, "type"), "notification sink '%s' has unknown type '%s'", sink.Name, sink.Type)
+		}
+		if sink.MinSeverity != "" && !notify.ValidSeverity(sink.MinSeverity) {
+			v.addf(fieldPath("notifications", "sinks", i, "min_severity"), "notification sink '%s' has unknown min_severity '%s'", sink.Name, sink.MinSeverity)
+		}
+		for j, ruleName := range sink.Rules {
+			if !ruleNames[ruleName] {
+				v.addf(fieldPath("notifications", "sinks", i, "rules", j), "notification sink '%s' references unknown rule '%s'", sink.Name, ruleName)
+			}
+		}
+		for j, eventType := range sink.Events {
+			if !eventTypes[eventType] {
+				v.addf(fieldPath("notifications", "sinks", i, "events", j), "notification sink '%s' references unknown event '%s'", sink.Name, eventType)
+			}
+		}
+	}
+}
+
+func isHttpUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
 func walletExists(walletName string, wallets []model.Wallet) bool {
 	for _, w := range wallets {
 		if w.Name == walletName {
@@ -80,17 +592,41 @@ func walletExists(walletName string, wallets []model.Wallet) bool {
 	return false
 }
 
-func validateColdWallets(config *model.Config) error {
-	client, err := GetClientFromEnv()
-	if err != nil {
-		return fmt.Errorf("cannot get client from environment %w", err)
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateColdWallets is the online phase: it checks that every configured
+// wallet exists in its Prime portfolio and holds the configured asset.
+func (v *validator) validateColdWallets(config *model.Config, clients ClientResolver) {
+	portfolioClients := make(map[string]PrimeClient)
+	for i, portfolio := range GetPortfolios(config) {
+		client, err := clients(portfolio)
+		if err != nil {
+			path := ""
+			if len(config.Portfolios) > 0 {
+				path = fieldPath("portfolios", i)
+			}
+			v.addf(path, "cannot get client for portfolio '%s': %v", portfolio.Name, err)
+			continue
+		}
+		portfolioClients[portfolio.Name] = client
 	}
 
-	for _, walletConfig := range config.Wallets {
+	for i, walletConfig := range config.Wallets {
+		client, exists := portfolioClients[WalletPortfolio(config, walletConfig)]
+		if !exists {
+			continue
+		}
 		ctx, cancel := GetContextWithTimeout(config)
 
 		request := &prime.GetWalletRequest{
-			PortfolioId: client.Credentials.PortfolioId,
+			PortfolioId: client.PortfolioId(),
 			Id:          walletConfig.WalletId,
 		}
 
@@ -98,17 +634,16 @@ func validateColdWallets(config *model.Config) error {
 		cancel()
 		if err != nil {
 			zap.L().Error("cannot get wallet", zap.String("wallet", walletConfig.Name), zap.Error(err))
-			return err
+			v.addf(fieldPath("wallets", i, "wallet_id"), "cannot get wallet '%s': %v", walletConfig.Name, err)
+			continue
 		}
 
 		if response.Wallet.Symbol != walletConfig.Asset {
-			return fmt.Errorf("asset mismatch for wallet '%s': expected '%s', got '%s'",
+			v.addf(fieldPath("wallets", i, "asset"), "asset mismatch for wallet '%s': expected '%s', got '%s'",
 				walletConfig.Name,
 				walletConfig.Asset,
 				response.Wallet.Symbol,
 			)
 		}
 	}
-
-	return nil
 }
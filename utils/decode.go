@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// decodeConfig parses raw strictly: unknown fields and values of the wrong
+// type, including durations that are not a whole number of seconds or
+// minutes, are returned as problems instead of being ignored. Only a file that
+// is not YAML at all is an error.
+func decodeConfig(raw []byte) (*model.Config, []Problem, error) {
+	config := &model.Config{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	err := decoder.Decode(config)
+
+	var typeError *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return config, nil, nil
+	case errors.As(err, &typeError):
+		var problems []Problem
+		for _, message := range typeError.Errors {
+			problems = append(problems, decodeProblem(message))
+		}
+		return config, problems, nil
+	default:
+		return nil, nil, err
+	}
+}
+
+// decodeProblem turns a yaml.v3 type error, e.g. "line 3: field foo not found
+// in type model.Rule", into a problem on that line.
+func decodeProblem(message string) Problem {
+	prefix, rest, found := strings.Cut(message, ": ")
+	if !found {
+		return Problem{Message: message}
+	}
+	line, err := strconv.Atoi(strings.TrimPrefix(prefix, "line "))
+	if err != nil || !strings.HasPrefix(prefix, "line ") {
+		return Problem{Message: message}
+	}
+	return Problem{Line: line, Message: rest}
+}
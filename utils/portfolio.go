@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/coinbase-samples/prime-sweeper-go/credentials"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"sync"
+)
+
+const DefaultPortfolioName = "default"
+
+// ClientResolver returns the Prime client for a portfolio.
+type ClientResolver func(portfolio model.Portfolio) (PrimeClient, error)
+
+// StaticClient resolves every portfolio to the same client.
+func StaticClient(client PrimeClient) ClientResolver {
+	return func(model.Portfolio) (PrimeClient, error) {
+		return client, nil
+	}
+}
+
+// CachedClients resolves each portfolio through clients once and returns the
+// same client for it afterwards, so that validating a config and setting it up
+// share their clients.
+func CachedClients(clients ClientResolver) ClientResolver {
+	var mu sync.Mutex
+	resolved := make(map[string]PrimeClient)
+	return func(portfolio model.Portfolio) (PrimeClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if client, exists := resolved[portfolio.Name]; exists {
+			return client, nil
+		}
+		client, err := clients(portfolio)
+		if err != nil {
+			return nil, err
+		}
+		resolved[portfolio.Name] = client
+		return client, nil
+	}
+}
+
+// GetClientForPortfolio builds a client from the portfolio's credentials
+// source: its credentials section, its credentials_env variable, or
+// PRIME_CREDENTIALS if neither is set.
+func GetClientForPortfolio(portfolio model.Portfolio) (PrimeClient, error) {
+	provider, err := credentials.FromConfig(portfolio)
+	if err != nil {
+		return nil, err
+	}
+	return newClientFromProvider(provider, portfolio.Name)
+}
+
+// FileClient resolves every portfolio to a client built from the JSON
+// credentials in the file at path.
+func FileClient(path string) ClientResolver {
+	return func(portfolio model.Portfolio) (PrimeClient, error) {
+		return newClientFromProvider(credentials.Cached(credentials.File(path), credentials.DefaultRefreshInterval), portfolio.Name)
+	}
+}
+
+func newClientFromProvider(provider credentials.Provider, portfolioName string) (PrimeClient, error) {
+	client, err := NewRotatingClient(context.Background(), provider)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load credentials for portfolio '%s': %w", portfolioName, err)
+	}
+	return client, nil
+}
+
+// GetPortfolios returns the configured portfolios, or a single default
+// portfolio using PRIME_CREDENTIALS when none are declared.
+func GetPortfolios(config *model.Config) []model.Portfolio {
+	if len(config.Portfolios) == 0 {
+		return []model.Portfolio{{Name: DefaultPortfolioName}}
+	}
+	return config.Portfolios
+}
+
+// resolvePortfolioName maps an empty portfolio reference to the only
+// portfolio when exactly one is configured.
+func resolvePortfolioName(config *model.Config, name string) string {
+	if name != "" {
+		return name
+	}
+	if portfolios := GetPortfolios(config); len(portfolios) == 1 {
+		return portfolios[0].Name
+	}
+	return ""
+}
+
+func RulePortfolio(config *model.Config, rule model.Rule) string {
+	return resolvePortfolioName(config, rule.Portfolio)
+}
+
+func WalletPortfolio(config *model.Config, wallet model.Wallet) string {
+	return resolvePortfolioName(config, wallet.Portfolio)
+}
+
+// ScopeConfig returns a copy of config holding only the rules and wallets of
+// the named portfolio.
+func ScopeConfig(config *model.Config, portfolioName string) *model.Config {
+	scoped := *config
+	scoped.Rules = nil
+	scoped.Wallets = nil
+	for _, rule := range config.Rules {
+		if RulePortfolio(config, rule) == portfolioName {
+			scoped.Rules = append(scoped.Rules, rule)
+		}
+	}
+	for _, wallet := range config.Wallets {
+		if WalletPortfolio(config, wallet) == portfolioName {
+			scoped.Wallets = append(scoped.Wallets, wallet)
+		}
+	}
+	return &scoped
+}
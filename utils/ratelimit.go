@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"context"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRequestsPerSecond = 10
+	defaultBurst             = 20
+)
+
+// Priority orders callers waiting on a RateLimiter. Waiting callers of a
+// higher priority are always served first.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	numPriorities
+)
+
+// RateLimiter is a token bucket shared by every Prime client of the process.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	queues  [numPriorities][]*rateWaiter
+	pending *time.Timer
+}
+
+type rateWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewRateLimiter returns a limiter allowing requestsPerSecond on average and
+// bursts of up to burst requests.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	l := &RateLimiter{last: time.Now()}
+	l.SetLimit(requestsPerSecond, burst)
+	l.tokens = l.burst
+	return l
+}
+
+// GetRateLimit returns the configured rate and burst, falling back to the
+// defaults for unset values.
+func GetRateLimit(config *model.Config) (float64, int) {
+	requestsPerSecond := config.Daemon.RateLimit.RequestsPerSecond
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = defaultRequestsPerSecond
+	}
+	burst := config.Daemon.RateLimit.Burst
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+	return requestsPerSecond, burst
+}
+
+// SetLimit changes the rate and burst without dropping waiting callers.
+func (l *RateLimiter) SetLimit(requestsPerSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.rate = requestsPerSecond
+	l.burst = math.Max(float64(burst), 1)
+	l.tokens = math.Min(l.tokens, l.burst)
+	if l.pending != nil {
+		l.pending.Stop()
+		l.pending = nil
+	}
+	l.dispatch()
+}
+
+// Wait blocks until a token is available for a caller of the given priority
+// or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, priority Priority) error {
+	waiter := &rateWaiter{ready: make(chan struct{})}
+
+	l.mu.Lock()
+	l.queues[priority] = append(l.queues[priority], waiter)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if waiter.granted {
+		return nil
+	}
+	queue := l.queues[priority]
+	for i, queued := range queue {
+		if queued == waiter {
+			l.queues[priority] = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// dispatch hands out available tokens in priority order and, if callers are
+// left waiting, arms a timer for when the next token becomes available. It
+// must be called with mu held.
+func (l *RateLimiter) dispatch() {
+	l.refill(time.Now())
+
+	waiting := false
+	for priority := range l.queues {
+		for len(l.queues[priority]) > 0 && l.tokens >= 1 {
+			waiter := l.queues[priority][0]
+			l.queues[priority] = l.queues[priority][1:]
+			l.tokens--
+			waiter.granted = true
+			close(waiter.ready)
+		}
+		if len(l.queues[priority]) > 0 {
+			waiting = true
+		}
+	}
+
+	if !waiting || l.pending != nil {
+		return
+	}
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.pending = time.AfterFunc(delay, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.pending = nil
+		l.dispatch()
+	})
+}
+
+type rateLimitedClient struct {
+	next    PrimeClient
+	limiter *RateLimiter
+}
+
+// NewRateLimitedClient makes every call of client wait on limiter. Transfer
+// creation has the highest priority and transaction status polling the
+// lowest, so that polling many in-flight transfers never delays new ones.
+func NewRateLimitedClient(client PrimeClient, limiter *RateLimiter) PrimeClient {
+	return &rateLimitedClient{next: client, limiter: limiter}
+}
+
+func (c *rateLimitedClient) PortfolioId() string {
+	return c.next.PortfolioId()
+}
+
+func (c *rateLimitedClient) ListWallets(
+	ctx context.Context,
+	request *prime.ListWalletsRequest,
+) (*prime.ListWalletsResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityNormal); err != nil {
+		return nil, err
+	}
+	return c.next.ListWallets(ctx, request)
+}
+
+func (c *rateLimitedClient) GetWallet(
+	ctx context.Context,
+	request *prime.GetWalletRequest,
+) (*prime.GetWalletResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityNormal); err != nil {
+		return nil, err
+	}
+	return c.next.GetWallet(ctx, request)
+}
+
+func (c *rateLimitedClient) GetWalletBalance(
+	ctx context.Context,
+	request *prime.GetWalletBalanceRequest,
+) (*prime.GetWalletBalanceResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityNormal); err != nil {
+		return nil, err
+	}
+	return c.next.GetWalletBalance(ctx, request)
+}
+
+func (c *rateLimitedClient) CreateWalletTransfer(
+	ctx context.Context,
+	request *prime.CreateWalletTransferRequest,
+) (*prime.CreateWalletTransferResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityHigh); err != nil {
+		return nil, err
+	}
+	return c.next.CreateWalletTransfer(ctx, request)
+}
+
+func (c *rateLimitedClient) GetActivity(
+	ctx context.Context,
+	request *prime.GetActivityRequest,
+) (*prime.GetActivityResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityNormal); err != nil {
+		return nil, err
+	}
+	return c.next.GetActivity(ctx, request)
+}
+
+func (c *rateLimitedClient) GetTransaction(
+	ctx context.Context,
+	request *prime.GetTransactionRequest,
+) (*prime.GetTransactionResponse, error) {
+	if err := c.limiter.Wait(ctx, PriorityLow); err != nil {
+		return nil, err
+	}
+	return c.next.GetTransaction(ctx, request)
+}
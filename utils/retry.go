@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/coinbase-samples/prime-sdk-go"
+	"github.com/coinbase-samples/prime-sweeper-go/model"
+	"go.uber.org/zap"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// statusCodePattern extracts the HTTP status from errors returned by the
+// Prime SDK, which only reports it as part of the message.
+var statusCodePattern = regexp.MustCompile(`received: (\d{3})`)
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	AttemptTimeout time.Duration
+}
+
+func GetRetryPolicy(config *model.Config) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		AttemptTimeout: getTimeoutDuration(config),
+	}
+
+	retry := config.Daemon.Retry
+	if retry.MaxAttempts > 0 {
+		policy.MaxAttempts = retry.MaxAttempts
+	}
+	if retry.InitialBackoffMs > 0 {
+		policy.InitialBackoff = time.Duration(retry.InitialBackoffMs) * time.Millisecond
+	}
+	if retry.MaxBackoffMs > 0 {
+		policy.MaxBackoff = time.Duration(retry.MaxBackoffMs) * time.Millisecond
+	}
+	return policy
+}
+
+// backoff returns the delay before the given retry (1 for the first retry):
+// exponential growth capped at MaxBackoff, with the upper half jittered so
+// that concurrent callers spread out.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff << (retry - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// StatusCode returns the HTTP status carried by a Prime SDK error.
+func StatusCode(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	match := statusCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(match[1])
+	return code, err == nil
+}
+
+// IsRetryable classifies err: network failures, timeouts, 429 and 5xx
+// responses are transient, while other 4xx responses and anything
+// unrecognized are permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if code, ok := StatusCode(err); ok {
+		return code == 429 || code >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type retryingClient struct {
+	next   PrimeClient
+	policy RetryPolicy
+}
+
+// NewRetryingClient wraps client so that transient failures are retried
+// according to policy. Each attempt gets its own AttemptTimeout within the
+// caller's context. Requests are resent unchanged, so transfer creation keeps
+// its idempotency key across attempts.
+func NewRetryingClient(client PrimeClient, policy RetryPolicy) PrimeClient {
+	if policy.MaxAttempts <= 1 {
+		return client
+	}
+	return &retryingClient{next: client, policy: policy}
+}
+
+func retryCall[T any](ctx context.Context, policy RetryPolicy, method string, call func(context.Context) (T, error)) (T, error) {
+	var response T
+	var err error
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, policy.AttemptTimeout)
+		response, err = call(attemptCtx)
+		cancel()
+
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return response, err
+		}
+
+		delay := policy.backoff(attempt)
+		zap.L().Warn("prime call failed, retrying",
+			zap.String("method", method),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return response, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *retryingClient) PortfolioId() string {
+	return c.next.PortfolioId()
+}
+
+func (c *retryingClient) ListWallets(
+	ctx context.Context,
+	request *prime.ListWalletsRequest,
+) (*prime.ListWalletsResponse, error) {
+	return retryCall(ctx, c.policy, "ListWallets", func(ctx context.Context) (*prime.ListWalletsResponse, error) {
+		return c.next.ListWallets(ctx, request)
+	})
+}
+
+func (c *retryingClient) GetWallet(
+	ctx context.Context,
+	request *prime.GetWalletRequest,
+) (*prime.GetWalletResponse, error) {
+	return retryCall(ctx, c.policy, "GetWallet", func(ctx context.Context) (*prime.GetWalletResponse, error) {
+		return c.next.GetWallet(ctx, request)
+	})
+}
+
+func (c *retryingClient) GetWalletBalance(
+	ctx context.Context,
+	request *prime.GetWalletBalanceRequest,
+) (*prime.GetWalletBalanceResponse, error) {
+	return retryCall(ctx, c.policy, "GetWalletBalance", func(ctx context.Context) (*prime.GetWalletBalanceResponse, error) {
+		return c.next.GetWalletBalance(ctx, request)
+	})
+}
+
+func (c *retryingClient) CreateWalletTransfer(
+	ctx context.Context,
+	request *prime.CreateWalletTransferRequest,
+) (*prime.CreateWalletTransferResponse, error) {
+	return retryCall(ctx, c.policy, "CreateWalletTransfer", func(ctx context.Context) (*prime.CreateWalletTransferResponse, error) {
+		return c.next.CreateWalletTransfer(ctx, request)
+	})
+}
+
+func (c *retryingClient) GetActivity(
+	ctx context.Context,
+	request *prime.GetActivityRequest,
+) (*prime.GetActivityResponse, error) {
+	return retryCall(ctx, c.policy, "GetActivity", func(ctx context.Context) (*prime.GetActivityResponse, error) {
+		return c.next.GetActivity(ctx, request)
+	})
+}
+
+func (c *retryingClient) GetTransaction(
+	ctx context.Context,
+	request *prime.GetTransactionRequest,
+) (*prime.GetTransactionResponse, error) {
+	return retryCall(ctx, c.policy, "GetTransaction", func(ctx context.Context) (*prime.GetTransactionResponse, error) {
+		return c.next.GetTransaction(ctx, request)
+	})
+}
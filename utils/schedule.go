@@ -0,0 +1,9 @@
+package utils
+
+import "github.com/robfig/cron/v3"
+
+// ScheduleParser is the seconds-enabled cron parser used for rule schedules,
+// both when validating the config and when scheduling rules.
+var ScheduleParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
@@ -2,16 +2,22 @@ package utils
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"github.com/coinbase-samples/prime-sdk-go"
 	"github.com/coinbase-samples/prime-sweeper-go/model"
-	"net/http"
-	"os"
 	"time"
 )
 
-const defaultTimeoutDuration time.Duration = 7
+const (
+	defaultTimeoutDuration time.Duration = 7
+	defaultLedgerPath                    = "sweeper.db"
+)
+
+// GetLedgerPath returns the configured ledger file, or sweeper.db.
+func GetLedgerPath(config *model.Config) string {
+	if config.Daemon.LedgerPath != "" {
+		return config.Daemon.LedgerPath
+	}
+	return defaultLedgerPath
+}
 
 func getTimeoutDuration(config *model.Config) time.Duration {
 	if config.Daemon.ContextTimeoutDuration > 0 {
@@ -21,22 +27,33 @@ func getTimeoutDuration(config *model.Config) time.Duration {
 	return defaultTimeoutDuration * time.Second
 }
 
+// GetContextWithTimeout returns the context for a single Prime call. Its
+// deadline leaves room for every retry attempt, each of which is bounded by
+// context_timeout_duration, plus the backoff between them.
 func GetContextWithTimeout(config *model.Config) (context.Context, context.CancelFunc) {
-	timeoutDuration := getTimeoutDuration(config)
+	policy := GetRetryPolicy(config)
+	timeoutDuration := policy.AttemptTimeout*time.Duration(policy.MaxAttempts) +
+		policy.MaxBackoff*time.Duration(policy.MaxAttempts-1)
 
 	return context.WithTimeout(context.Background(), timeoutDuration)
 }
 
-func GetClientFromEnv() (*prime.Client, error) {
-	credentials := &prime.Credentials{}
-	if err := json.Unmarshal([]byte(os.Getenv("PRIME_CREDENTIALS")), credentials); err != nil {
-		return nil, fmt.Errorf("cannot unmarshall credentials %w", err)
-	}
+func GetClientFromEnv() (PrimeClient, error) {
+	return GetClientForPortfolio(model.Portfolio{Name: DefaultPortfolioName})
+}
 
-	client := prime.NewClient(credentials, http.Client{})
-	return client, nil
+// IsAwaitingApproval reports whether a transaction is waiting for consensus
+// approval in Prime.
+func IsAwaitingApproval(status string) bool {
+	return status == "TRANSACTION_CREATED" || status == "TRANSACTION_REQUESTED"
 }
 
+// LastStatusIsTerminal reports whether a Prime transaction status is final.
 func LastStatusIsTerminal(status string) bool {
-	return status == "TRANSACTION_DONE" || status == "TRANSACTION_REJECTED" || status == "TRANSACTION_FAILED"
+	switch status {
+	case "TRANSACTION_DONE", "TRANSACTION_REJECTED", "TRANSACTION_FAILED",
+		"TRANSACTION_CANCELLED", "TRANSACTION_EXPIRED":
+		return true
+	}
+	return false
 }
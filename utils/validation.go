@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Problem is a single reason a config is invalid. Path locates the offending
+// field, e.g. rules[1].schedule, and Line is its line in the config file, or 0
+// when unknown.
+type Problem struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var prefix string
+	if p.Line > 0 {
+		prefix = fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Path != "" {
+		prefix += p.Path + ": "
+	}
+	return prefix + p.Message
+}
+
+// ValidationError reports every problem found in a config file.
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("invalid config %s: %s", e.File, e.Problems[0])
+	}
+
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("invalid config %s: %d problems", e.File, len(e.Problems)))
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator collects problems instead of stopping at the first one.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns a ValidationError with the line of every problem resolved
+// against the raw config, or nil when there are no problems.
+func (v *validator) err(filename string, raw []byte) error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(raw, &document); err == nil {
+		for i := range v.problems {
+			if v.problems[i].Line == 0 {
+				v.problems[i].Line = findLine(&document, v.problems[i].Path)
+			}
+		}
+	}
+	return &ValidationError{File: filename, Problems: v.problems}
+}
+
+// fieldPath joins path elements: strings are mapping keys and ints are
+// sequence indexes, e.g. fieldPath("rules", 1, "schedule") is
+// "rules[1].schedule".
+func fieldPath(elements ...interface{}) string {
+	var path strings.Builder
+	for _, element := range elements {
+		switch element := element.(type) {
+		case int:
+			fmt.Fprintf(&path, "[%d]", element)
+		default:
+			if path.Len() > 0 {
+				path.WriteByte('.')
+			}
+			fmt.Fprint(&path, element)
+		}
+	}
+	return path.String()
+}
+
+var pathElementPattern = regexp.MustCompile(`\[(\d+)\]|([^.\[\]]+)`)
+
+// findLine returns the line of the node at path, or of its closest existing
+// ancestor when the field is missing.
+func findLine(document *yaml.Node, path string) int {
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := 0
+
+	for _, match := range pathElementPattern.FindAllStringSubmatch(path, -1) {
+		var next *yaml.Node
+		if match[1] != "" {
+			index, _ := strconv.Atoi(match[1])
+			if node.Kind == yaml.SequenceNode && index < len(node.Content) {
+				next = node.Content[index]
+				line = next.Line
+			}
+		} else if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == match[2] {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}